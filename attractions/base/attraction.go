@@ -1,12 +1,15 @@
 package base
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"kubepark/pkg/directory"
 	"kubepark/pkg/httptypes"
 	"kubepark/pkg/k8s"
 	"kubepark/pkg/logger"
+	"kubepark/pkg/settings"
+	"kubepark/pkg/tracing"
 	"log/slog"
 	"math/rand/v2"
 	"net/http"
@@ -22,14 +25,25 @@ type Attraction struct {
 	MetricsServer *http.Server
 	MainServer    *http.Server
 	State         *StateManager
+	Settings      *settings.Watcher
+	Park          *ParkStatus
+
+	rides           *rides
+	stopMaintenance context.CancelFunc // Cuts repairs and cleaning in progress short
+	shutdownTracing func() error
 }
 
 // New creates a new base attraction
 func New(config *Config, defaultFee float64, afterUse func() error) *Attraction {
 	RegisterFlags(config, defaultFee)
 
-	// Initialize logger with configured level
-	logger.InitLogger(config.LogLevel)
+	// Initialize logger with configured level and format
+	logger.Init(logger.Options{
+		Level:     config.LogLevel,
+		Format:    config.LogFormat,
+		Component: "attraction",
+		Attrs:     []any{"attraction", config.Name, "instance", config.Instance},
+	})
 
 	// Initialize state manager
 	state, err := NewStateManager(config.VolumePath)
@@ -38,39 +52,86 @@ func New(config *Config, defaultFee float64, afterUse func() error) *Attraction
 		panic(err)
 	}
 
+	// Trace rides from the guest through the attraction to the park
+	shutdownTracing, err := tracing.Init(context.Background(), config.Name)
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "error", err)
+		panic(err)
+	}
+
 	r := prometheus.NewRegistry()
-	RegisterAttractionMetrics(r)
-	Metrics.Fee.Set(config.Fee)
-	Metrics.IsAttractionClosed.Set(btof(config.Closed))
+	RegisterAttractionMetrics(r, config)
+
+	// Apply the settings file on top of the flags, and keep watching it for changes
+	settingsApplier := newSettingsApplier(config, state)
+	settingsWatcher := settings.NewWatcher(config.SettingsPath, settings.Interval, settingsApplier.Apply)
+	if err := settingsWatcher.Load(); err != nil {
+		slog.Error("Failed to apply settings file, falling back to flags", "path", config.SettingsPath, "error", err)
+		if err := settingsApplier.Apply(nil); err != nil {
+			slog.Error("Failed to apply settings", "error", err)
+			panic(err)
+		}
+	}
 
 	// Create metrics server on port 9000
 	metricsMux := http.NewServeMux()
 	metricsMux.Handle("/metrics", promhttp.HandlerFor(r, promhttp.HandlerOpts{}))
+	metricsMux.HandleFunc("/log-level", logger.HandleLevel())
 	metricsServer := &http.Server{
 		Addr:    ":9000",
 		Handler: metricsMux,
 	}
 
+	// Staff are told apart by reviewing their ServiceAccount tokens
+	clientset, err := k8s.NewClient()
+	if err != nil {
+		slog.Error("Failed to create Kubernetes client", "error", err)
+		panic(err)
+	}
+
+	park := &ParkStatus{}
+	rides := newRides()
+	maintenance := &maintenance{}
+	maintenanceCtx, stopMaintenance := context.WithCancel(context.Background())
+
 	// Create main server on port 80
 	mainMux := http.NewServeMux()
-	mainMux.HandleFunc("/use", handleUse(config, state, afterUse))
-	mainMux.HandleFunc("/attraction-status", handleAttractionStatus(config, state))
+	mainMux.HandleFunc("/use", handleUse(config, state, park, rides, afterUse))
+	mainMux.HandleFunc("/attraction-status", handleAttractionStatus(config, state, park))
+	mainMux.HandleFunc("/settings", handleSettings(state))
+	mainMux.HandleFunc("/repair", handleRepair(maintenanceCtx, config, state, maintenance, clientset))
+	mainMux.HandleFunc("/clean", handleClean(maintenanceCtx, config, state, maintenance, clientset))
 	mainServer := &http.Server{
 		Addr:    ":80",
-		Handler: mainMux,
+		Handler: tracing.Handler(mainMux, config.Name),
 	}
 
 	return &Attraction{
-		Config:        config,
-		MetricsServer: metricsServer,
-		MainServer:    mainServer,
-		State:         state,
+		Config:          config,
+		MetricsServer:   metricsServer,
+		MainServer:      mainServer,
+		State:           state,
+		Settings:        settingsWatcher,
+		Park:            park,
+		rides:           rides,
+		stopMaintenance: stopMaintenance,
+		shutdownTracing: shutdownTracing,
 	}
 }
 
 // BeforeStart checks if there's enough space in the park and enough money to build the attraction.
-func (a *Attraction) BeforeStart() error {
-	resp, err := http.Get(a.Config.ParkURL + "/park-status")
+func (a *Attraction) BeforeStart(ctx context.Context) error {
+	if a.State.GetParkKey() == "" {
+		if err := RequestCredentials(ctx, a.Config, a.State); err != nil {
+			return fmt.Errorf("failed to get park credentials: %v", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.Config.ParkURL+"/park-status", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := parkClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to get park status: %v", err)
 	}
@@ -90,7 +151,7 @@ func (a *Attraction) BeforeStart() error {
 			return fmt.Errorf("not enough money to repair attraction")
 		}
 
-		if err := ParkTransaction(a.Config, -a.Config.RepairCost); err != nil {
+		if err := ParkTransaction(ctx, a.Config, a.State, httptypes.CategoryRepair, -a.Config.RepairCost); err != nil {
 			return fmt.Errorf("failed to pay for repair: %v", err)
 		}
 
@@ -107,6 +168,10 @@ func (a *Attraction) BeforeStart() error {
 		return nil
 	}
 
+	if park.Bankrupt {
+		return fmt.Errorf("cannot build attraction when park is bankrupt")
+	}
+
 	if !park.IsClosed {
 		return fmt.Errorf("cannot build attraction when park is open")
 	}
@@ -115,21 +180,23 @@ func (a *Attraction) BeforeStart() error {
 		return fmt.Errorf("not enough money to build attraction")
 	}
 
-	attractions, err := k8s.DiscoverAttractions()
+	attractions, err := directory.Attractions(ctx, a.Config.ParkURL)
 	if err != nil {
 		return fmt.Errorf("failed to discover attractions: %v", err)
 	}
 
 	usedSpace := 0.0
 	for _, attraction := range attractions {
-		usedSpace += attraction.Size
+		if attraction.Instance != a.Config.Instance {
+			usedSpace += attraction.Size
+		}
 	}
 
 	if park.TotalSpace-usedSpace < a.Config.Size {
 		return fmt.Errorf("not enough space in the park")
 	}
 
-	if err := ParkTransaction(a.Config, -a.Config.BuildCost); err != nil {
+	if err := ParkTransaction(ctx, a.Config, a.State, httptypes.CategoryBuild, -a.Config.BuildCost); err != nil {
 		return fmt.Errorf("failed to pay for build: %v", err)
 	}
 
@@ -142,11 +209,11 @@ func (a *Attraction) BeforeStart() error {
 	return nil
 }
 
-// Start starts both the metrics and main HTTP servers
-func (a *Attraction) Start() error {
+// Start starts both the metrics and main HTTP servers, and runs the attraction until the context is done
+func (a *Attraction) Start(ctx context.Context) error {
 	// Register with park
 	slog.Info("Checking if attraction can start")
-	if err := a.BeforeStart(); err != nil {
+	if err := a.BeforeStart(ctx); err != nil {
 		return fmt.Errorf("failed check to see if attraction can start: %v", err)
 	}
 
@@ -159,13 +226,33 @@ func (a *Attraction) Start() error {
 		}
 	}()
 
+	// Apply changes to the settings file while the attraction runs
+	a.Settings.Start(ctx)
+
 	// Start the attraction simulation loop
 	go func() {
 		slog.Info("Starting attraction simulation loop")
+		started := time.Now()
 		ticker := time.NewTicker(time.Second)
 		defer ticker.Stop()
 
-		for range ticker.C {
+		for tick := 0; ; tick++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// Keep up with the park's weather and clock, stay in its directory and pay the upkeep of the hours gone by
+			if tick%parkInterval == 0 {
+				a.syncPark(ctx)
+			}
+			Metrics.IsAttractionClosed.Set(btof(closedReason(a.Config, a.State, a.Park) != ""))
+			Metrics.Upkeep.Set(upkeepRate(a.Config, isOperating(a.Config, a.State, a.Park)))
+			Metrics.IsBroken.Set(btof(a.State.IsBroken()))
+			Metrics.Uptime.Set(time.Since(started).Seconds())
+			Metrics.Dirtiness.Set(a.State.GetDirtiness())
+
 			// Random chance to break the attraction (0.1% chance per second)
 			if !a.State.IsBroken() && rand.Float64() < 0.001 {
 				slog.Info("Attraction has broken down", "name", a.Config.Name)
@@ -178,27 +265,62 @@ func (a *Attraction) Start() error {
 	}()
 
 	// Start main server
-	slog.Info("Starting main server on port 80")
-	return a.MainServer.ListenAndServe()
-}
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting main server on port 80")
+		serveErr <- a.MainServer.ListenAndServe()
+	}()
 
-// Stop gracefully stops both HTTP servers
-func (a *Attraction) Stop() error {
-	if err := a.MetricsServer.Close(); err != nil {
+	select {
+	case err := <-serveErr:
 		return err
+	case <-ctx.Done():
+	}
+
+	// The simulation loop gives up on its calls to the park by itself, so it doesn't hold up the drain
+	slog.Info("Shutting down attraction", "name", a.Config.Name)
+	return a.Stop()
+}
+
+// syncPark refreshes the park status, sends a heartbeat and pays the upkeep, giving each call
+// to the park a timeout. Refreshing and the heartbeat are given up on once the attraction shuts
+// down, but a payment already on its way isn't cut off halfway.
+func (a *Attraction) syncPark(ctx context.Context) {
+	refreshCtx, cancel := context.WithTimeout(ctx, parkTimeout)
+	defer cancel()
+	if err := a.Park.Refresh(refreshCtx, a.Config.ParkURL); err != nil {
+		slog.Warn("Failed to refresh park status", "error", err)
+	}
+
+	heartbeatCtx, cancel := context.WithTimeout(ctx, parkTimeout)
+	defer cancel()
+	if err := Heartbeat(heartbeatCtx, a.Config, a.State, a.Park); err != nil {
+		slog.Warn("Failed to send heartbeat to park", "error", err)
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+	upkeepCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), parkTimeout)
+	defer cancel()
+	if err := payUpkeep(upkeepCtx, a.Config, a.State, a.Park); err != nil {
+		slog.Error("Failed to pay upkeep", "error", err)
 	}
-	return a.MainServer.Close()
 }
 
-// ParkTransaction processes a transaction with the park
-func ParkTransaction(config *Config, amount float64) error {
-	req := httptypes.TransactionRequest{Amount: amount}
+// ParkTransaction processes a signed transaction with the park
+func ParkTransaction(ctx context.Context, config *Config, state *StateManager, category httptypes.TransactionCategory, amount float64) error {
+	req := httptypes.TransactionRequest{
+		Amount:     amount,
+		Category:   category,
+		Attraction: config.Name,
+	}
 	data, err := json.Marshal(req)
 	if err != nil {
 		return err
 	}
 
-	resp, err := http.Post(config.ParkURL+"/transaction", "application/json", bytes.NewBuffer(data))
+	resp, err := sendSigned(ctx, config, state, "/transaction", data)
 	if err != nil {
 		return err
 	}
@@ -211,7 +333,7 @@ func ParkTransaction(config *Config, amount float64) error {
 	if amount > 0 {
 		Metrics.Revenue.Add(amount)
 	} else {
-		Metrics.Costs.Add(-amount)
+		Metrics.Costs.WithLabelValues(string(category)).Add(-amount)
 	}
 
 	return nil
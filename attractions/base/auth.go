@@ -0,0 +1,94 @@
+package base
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"kubepark/pkg/auth"
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/tracing"
+)
+
+// RequestCredentials exchanges the pod's ServiceAccount token for a transaction signing key
+// and persists it in the attraction state
+func RequestCredentials(ctx context.Context, config *Config, state *StateManager) error {
+	token, err := os.ReadFile(auth.ServiceAccountTokenPath)
+	if err != nil {
+		return fmt.Errorf("failed to read service account token: %v", err)
+	}
+
+	data, err := json.Marshal(httptypes.CredentialsRequest{
+		Attraction: config.Name,
+		Instance:   config.Instance,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.ParkURL+"/credentials", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	resp, err := parkClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("credentials request failed with status: %d", resp.StatusCode)
+	}
+
+	var credentials httptypes.CredentialsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&credentials); err != nil {
+		return fmt.Errorf("failed to decode credentials: %v", err)
+	}
+
+	return state.SetParkKey(credentials.Key)
+}
+
+// parkClient passes the trace of a request on to the park, and gives up on a park that doesn't answer
+var parkClient = tracing.NewClient(parkTimeout)
+
+// postSigned sends a signed POST request to the park
+func postSigned(ctx context.Context, config *Config, state *StateManager, path string, data []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.ParkURL+path, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := auth.Sign(req, config.Instance, state.GetParkKey(), data); err != nil {
+		return nil, err
+	}
+
+	return parkClient.Do(req)
+}
+
+// sendSigned sends a signed POST request to the park, renewing the credentials once if the park
+// no longer knows them
+func sendSigned(ctx context.Context, config *Config, state *StateManager, path string, data []byte) (*http.Response, error) {
+	resp, err := postSigned(ctx, config, state, path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The park no longer knows our key, so get a new one and try once more
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		if err := RequestCredentials(ctx, config, state); err != nil {
+			return nil, fmt.Errorf("failed to renew park credentials: %v", err)
+		}
+		return postSigned(ctx, config, state, path, data)
+	}
+
+	return resp, nil
+}
@@ -2,6 +2,8 @@ package base
 
 import (
 	"flag"
+	"kubepark/pkg/logger"
+	"os"
 	"time"
 )
 
@@ -10,20 +12,46 @@ type Config struct {
 	Closed     bool
 	Fee        float64
 	ParkURL    string
+	URL        string // Where guests and staff reach the attraction
 	Name       string
+	Instance   string
 	Duration   time.Duration
 	BuildCost  float64
 	RepairCost float64
 	Size       float64 // Size in acres
-	VolumePath string
-	LogLevel   string
+
+	UpkeepOpen   float64 // Upkeep per simulated hour while the attraction is open to guests
+	UpkeepClosed float64 // Upkeep per simulated hour while the attraction or the park is closed
+
+	ClosedInWeather []string // Weather conditions the attraction closes in
+
+	RepairDuration time.Duration // How long a mechanic takes to repair the attraction
+	CleanDuration  time.Duration // How long a janitor takes to clean the attraction
+	DirtPerUse     float64       // Dirtiness each use adds, the attraction is too dirty to use at 1
+	VolumePath     string
+	LogLevel       string
+	LogFormat      string
+
+	SettingsPath string
 }
 
 func RegisterFlags(config *Config, defaultFee float64) {
+	flag.StringVar(&config.SettingsPath, "settings", "", "Path to a YAML or JSON settings file that is applied live and takes precedence over flags")
 	flag.BoolVar(&config.Closed, "closed", false, "Whether the attraction is closed")
 	flag.StringVar(&config.ParkURL, "park-url", "http://kubepark:80", "URL of the kubepark service")
+	flag.StringVar(&config.URL, "url", podURL(), "URL guests and staff reach the attraction at, where its heartbeats come from if empty")
+	flag.StringVar(&config.Instance, "instance", os.Getenv("HOSTNAME"), "Name of this attraction instance, used to identify it to the park")
 	flag.Float64Var(&config.Fee, "fee", defaultFee, "Fee for using the attraction")
 	flag.StringVar(&config.VolumePath, "volume", "", "Path to volume for persistent storage")
 	flag.StringVar(&config.LogLevel, "log-level", "info", "Log level (debug, info, warn, error)")
+	flag.StringVar(&config.LogFormat, "log-format", logger.FormatText, "Log format (text, json)")
 	flag.Parse()
 }
+
+// podURL returns the URL of the attraction's pod when its IP is known
+func podURL() string {
+	if ip := os.Getenv("POD_IP"); ip != "" {
+		return "http://" + ip
+	}
+	return ""
+}
@@ -1,74 +1,265 @@
 package base
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"kubepark/pkg/httptypes"
+	"kubepark/pkg/k8s"
+
+	"k8s.io/client-go/kubernetes"
 )
 
 // handleAttractionStatus handles the attraction-status endpoint
-func handleAttractionStatus(config *Config, state *StateManager) http.HandlerFunc {
+func handleAttractionStatus(config *Config, state *StateManager, park *ParkStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status(config, state, park))
+	}
+}
+
+// handleSettings handles requests for the settings the attraction runs with and their audit log
+func handleSettings(state *StateManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// Return the attraction's fee
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(httptypes.Attraction{
-			Fee:  config.Fee,
-			Size: config.Size,
-		})
+		json.NewEncoder(w).Encode(state.GetSettingsStatus())
 	}
 }
 
 // HandleUse handles the common use endpoint functionality
-func handleUse(config *Config, state *StateManager, afterUse func() error) http.HandlerFunc {
+func handleUse(config *Config, state *StateManager, park *ParkStatus, rides *rides, afterUse func() error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
+		// Time serving the guest, whether it gets to ride or is turned away
+		served := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		w = sw
+		defer func() {
+			success := strconv.FormatBool(sw.status == http.StatusOK)
+			Metrics.ServeDuration.WithLabelValues(success).Observe(time.Since(served).Seconds())
+		}()
+
 		if state.IsBroken() {
-			Metrics.AttractionAttempts.WithLabelValues("false", "attraction_broken").Inc()
-			http.Error(w, fmt.Sprintf("%s is broken", config.Name), http.StatusServiceUnavailable)
+			refuse(w, "attraction_broken", fmt.Sprintf("%s is broken", config.Name), http.StatusServiceUnavailable)
 			return
 		}
 
-		if config.Closed {
-			Metrics.AttractionAttempts.WithLabelValues("false", "attraction_closed").Inc()
-			http.Error(w, fmt.Sprintf("%s is closed", config.Name), http.StatusServiceUnavailable)
+		switch closedReason(config, state, park) {
+		case "attraction_closed":
+			refuse(w, "attraction_closed", fmt.Sprintf("%s is closed", config.Name), http.StatusServiceUnavailable)
+			return
+		case "weather_closed":
+			refuse(w, "weather_closed", fmt.Sprintf("%s is closed in %s weather", config.Name, park.Weather()), http.StatusServiceUnavailable)
+			return
+		}
+
+		if state.GetDirtiness() >= 1 {
+			refuse(w, "attraction_dirty", fmt.Sprintf("%s is too dirty to use", config.Name), http.StatusServiceUnavailable)
 			return
 		}
 
+		// Settle the fee with the park even if the guest hangs up mid-ride
+		ctx := context.WithoutCancel(r.Context())
+
+		// Guests arriving while rides are interrupted are turned away before they pay
+		if !rides.begin() {
+			refuse(w, "attraction_stopping", fmt.Sprintf("%s is shutting down", config.Name), http.StatusServiceUnavailable)
+			return
+		}
+		defer rides.end()
+
 		// Process payment with kubepark
-		if err := ParkTransaction(config, config.Fee); err != nil {
-			slog.Error("Failed to process payment", "error", err)
-			Metrics.AttractionAttempts.WithLabelValues("false", "payment_failed").Inc()
-			http.Error(w, "Payment failed", http.StatusInternalServerError)
+		fee := state.GetSettings().Fee
+		if err := ParkTransaction(ctx, config, state, httptypes.CategoryRideFee, fee); err != nil {
+			slog.ErrorContext(ctx, "Failed to process payment", "error", err)
+			refuse(w, "payment_failed", "Payment failed", http.StatusInternalServerError)
 			return
 		}
 
-		// Simulate usage duration
-		time.Sleep(config.Duration)
+		// Simulate usage duration, unless the attraction shuts down before the ride ends
+		boarded := time.Now()
+		select {
+		case <-time.After(config.Duration):
+		case <-rides.Interrupted():
+			if err := ParkTransaction(ctx, config, state, httptypes.CategoryRefund, -fee); err != nil {
+				slog.ErrorContext(ctx, "Failed to refund interrupted ride", "error", err)
+				refuse(w, "ride_interrupted", fmt.Sprintf("%s shut down during the ride, the fee could not be refunded", config.Name), http.StatusServiceUnavailable)
+				return
+			}
+			refuse(w, "ride_interrupted", fmt.Sprintf("%s shut down during the ride, the fee was refunded", config.Name), http.StatusServiceUnavailable)
+			return
+		}
+		Metrics.RideDuration.Observe(time.Since(boarded).Seconds())
 
 		// Call after use hook if set
 		if afterUse != nil {
 			if err := afterUse(); err != nil {
-				slog.Error("After use hook failed", "error", err)
-				Metrics.AttractionAttempts.WithLabelValues("false", "hook_failed").Inc()
-				http.Error(w, "Failed to cleanup attraction", http.StatusInternalServerError)
+				slog.ErrorContext(ctx, "After use hook failed", "error", err)
+				refuse(w, "hook_failed", "Failed to cleanup attraction", http.StatusInternalServerError)
 				return
 			}
 		}
 
+		if err := state.AddDirt(config.DirtPerUse); err != nil {
+			slog.ErrorContext(ctx, "Failed to update dirtiness", "error", err)
+		}
+
 		Metrics.AttractionAttempts.WithLabelValues("true", "success").Inc()
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// maintenance keeps several staff from working on the same job at once
+type maintenance struct {
+	repairing sync.Mutex
+	cleaning  sync.Mutex
+}
+
+// authorizeStaff checks that the request carries the ServiceAccount token of a member of staff,
+// refuses it otherwise, and returns who the member of staff is
+func authorizeStaff(w http.ResponseWriter, r *http.Request, clientset kubernetes.Interface) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		http.Error(w, "Missing service account token", http.StatusUnauthorized)
+		return "", false
+	}
+
+	name, err := k8s.ReviewStaffToken(r.Context(), clientset, token)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Refused maintenance", "path", r.URL.Path, "error", err)
+		http.Error(w, "Only staff may work on the attraction", http.StatusForbidden)
+		return "", false
+	}
+
+	return name, true
+}
+
+// handleRepair handles a mechanic repairing the attraction, which takes the repair duration
+// and costs the repair cost. The repair is abandoned when the attraction shuts down.
+func handleRepair(shutdown context.Context, config *Config, state *StateManager, m *maintenance, clientset kubernetes.Interface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		mechanic, ok := authorizeStaff(w, r, clientset)
+		if !ok {
+			return
+		}
+
+		if !state.IsBroken() {
+			http.Error(w, fmt.Sprintf("%s is not broken", config.Name), http.StatusConflict)
+			return
+		}
+
+		if !m.repairing.TryLock() {
+			http.Error(w, fmt.Sprintf("%s is already being repaired", config.Name), http.StatusConflict)
+			return
+		}
+		defer m.repairing.Unlock()
+
+		slog.Info("Mechanic started repairing attraction", "name", config.Name, "mechanic", mechanic, "duration", config.RepairDuration)
+		select {
+		case <-time.After(config.RepairDuration):
+		case <-shutdown.Done():
+			http.Error(w, fmt.Sprintf("%s shut down during the repair", config.Name), http.StatusServiceUnavailable)
+			return
+		}
+
+		if err := ParkTransaction(context.WithoutCancel(r.Context()), config, state, httptypes.CategoryRepair, -config.RepairCost); err != nil {
+			slog.Error("Failed to pay for repair", "error", err)
+			http.Error(w, "Failed to pay for repair", http.StatusBadGateway)
+			return
+		}
+
+		if err := state.SetBroken(false); err != nil {
+			slog.Error("Failed to set attraction not broken", "error", err)
+			http.Error(w, "Failed to finish repair", http.StatusInternalServerError)
+			return
+		}
+
+		Metrics.Maintenance.WithLabelValues("repair").Inc()
+		slog.Info("Mechanic repaired attraction", "name", config.Name, "mechanic", mechanic)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// handleClean handles a janitor cleaning the attraction, which takes the clean duration.
+// The cleaning is abandoned when the attraction shuts down.
+func handleClean(shutdown context.Context, config *Config, state *StateManager, m *maintenance, clientset kubernetes.Interface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		janitor, ok := authorizeStaff(w, r, clientset)
+		if !ok {
+			return
+		}
+
+		if !m.cleaning.TryLock() {
+			http.Error(w, fmt.Sprintf("%s is already being cleaned", config.Name), http.StatusConflict)
+			return
+		}
+		defer m.cleaning.Unlock()
+
+		select {
+		case <-time.After(config.CleanDuration):
+		case <-shutdown.Done():
+			http.Error(w, fmt.Sprintf("%s shut down during the cleaning", config.Name), http.StatusServiceUnavailable)
+			return
+		}
+
+		if err := state.Clean(); err != nil {
+			slog.Error("Failed to clean attraction", "error", err)
+			http.Error(w, "Failed to finish cleaning", http.StatusInternalServerError)
+			return
+		}
+
+		Metrics.Maintenance.WithLabelValues("clean").Inc()
+		slog.Info("Janitor cleaned attraction", "name", config.Name, "janitor", janitor)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// statusWriter remembers the status code written to a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code and writes it
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// refuse records a failed attempt to use the attraction and tells the guest why
+func refuse(w http.ResponseWriter, reason string, message string, status int) {
+	Metrics.AttractionAttempts.WithLabelValues("false", reason).Inc()
+	w.Header().Set(httptypes.HeaderReason, reason)
+	http.Error(w, message, status)
+}
@@ -0,0 +1,68 @@
+package base
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeTokenReviews answers token reviews with the users the tokens belong to
+func fakeTokenReviews(users map[string]authenticationv1.UserInfo) *fake.Clientset {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		user, ok := users[review.Spec.Token]
+		review.Status = authenticationv1.TokenReviewStatus{Authenticated: ok, User: user}
+		return true, review, nil
+	})
+	return clientset
+}
+
+func TestHandleCleanRequiresStaff(t *testing.T) {
+	state, err := NewStateManager("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientset := fakeTokenReviews(map[string]authenticationv1.UserInfo{
+		"janitor": {
+			Username: "system:serviceaccount:staff:default",
+			Extra:    map[string]authenticationv1.ExtraValue{"authentication.kubernetes.io/pod-name": {"janitor-abc"}},
+		},
+		"guest": {
+			Username: "system:serviceaccount:guests:default",
+			Extra:    map[string]authenticationv1.ExtraValue{"authentication.kubernetes.io/pod-name": {"guest-abc"}},
+		},
+	})
+	handler := handleClean(context.Background(), &Config{Name: "carousel"}, state, &maintenance{}, clientset)
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"unknown token", "forged", http.StatusForbidden},
+		{"not staff", "guest", http.StatusForbidden},
+		{"janitor", "janitor", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/clean", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -4,46 +4,140 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// metricsNamespace prefixes the name of every attraction metric
+const metricsNamespace = "attraction"
+
 var Metrics = struct {
 	Revenue            prometheus.Counter
-	Costs              prometheus.Counter
+	Costs              *prometheus.CounterVec
 	Fee                prometheus.Gauge
 	IsAttractionClosed prometheus.Gauge
-	AttractionAttempts prometheus.CounterVec
+	AttractionAttempts *prometheus.CounterVec
+	SettingsReloads    *prometheus.CounterVec
+	IsBroken           prometheus.Gauge
+	Uptime             prometheus.Gauge
+	Dirtiness          prometheus.Gauge
+	Maintenance        *prometheus.CounterVec
+	Upkeep             prometheus.Gauge
+	RideDuration       prometheus.Histogram
+	ServeDuration      *prometheus.HistogramVec
 }{
 	Revenue: prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "revenue",
-		Help: "Total revenue generated by the attraction",
+		Namespace: metricsNamespace,
+		Name:      "revenue_total",
+		Help:      "Total revenue generated by the attraction",
 	}),
 
-	Costs: prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "costs",
-		Help: "Total costs incurred by the attraction",
-	}),
+	Costs: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "costs_total",
+			Help:      "Total costs incurred by the attraction, by transaction category",
+		},
+		[]string{"category"},
+	),
 
 	Fee: prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "fee",
-		Help: "Current fee for using the attraction",
+		Namespace: metricsNamespace,
+		Name:      "fee",
+		Help:      "Current fee for using the attraction",
 	}),
 
 	IsAttractionClosed: prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "is_closed",
-		Help: "Whether the attraction is closed (1) or open (0)",
+		Namespace: metricsNamespace,
+		Name:      "is_closed",
+		Help:      "Whether the attraction is closed (1) or open (0)",
 	}),
 
-	AttractionAttempts: *prometheus.NewCounterVec(
+	AttractionAttempts: prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Name: "attempts",
-			Help: "Number of attempts to use the attraction",
+			Namespace: metricsNamespace,
+			Name:      "attempts_total",
+			Help:      "Number of attempts to use the attraction",
 		},
 		[]string{"success", "reason"},
 	),
+
+	SettingsReloads: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "settings_reloads_total",
+			Help:      "Number of times the attraction settings were loaded, by result (applied, invalid)",
+		},
+		[]string{"result"},
+	),
+
+	IsBroken: prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "is_broken",
+		Help:      "Whether the attraction is broken (1) or working (0)",
+	}),
+
+	Uptime: prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "uptime_seconds",
+		Help:      "Seconds since the attraction started",
+	}),
+
+	Dirtiness: prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "dirtiness",
+		Help:      "How dirty the attraction is, from 0 for spotless to 1 for too dirty to use",
+	}),
+
+	Maintenance: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "maintenance_total",
+			Help:      "Number of maintenance jobs done by staff",
+		},
+		[]string{"job"},
+	),
+
+	Upkeep: prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "upkeep",
+		Help:      "Current upkeep of the attraction per simulated hour",
+	}),
+
+	RideDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "ride_duration_seconds",
+		Help:      "How long rides take, from the guest boarding to getting off",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 9),
+	}),
+
+	ServeDuration: prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "serve_duration_seconds",
+			Help:      "How long it takes to serve a guest asking to use the attraction, including the ride",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+		},
+		[]string{"success"},
+	),
 }
 
-// RegisterAttractionMetrics registers all attraction-specific metrics
-func RegisterAttractionMetrics(r *prometheus.Registry) {
-	r.MustRegister(Metrics.Revenue)
-	r.MustRegister(Metrics.Fee)
-	r.MustRegister(Metrics.IsAttractionClosed)
-	r.MustRegister(Metrics.AttractionAttempts)
+// RegisterAttractionMetrics registers all attraction-specific metrics, labeled with the
+// attraction type and instance so dashboards can tell attractions apart. The instance label
+// isn't called instance, which Prometheus reserves for the scrape target.
+func RegisterAttractionMetrics(r prometheus.Registerer, config *Config) {
+	labeled := prometheus.WrapRegistererWith(prometheus.Labels{
+		"attraction":          config.Name,
+		"attraction_instance": config.Instance,
+	}, r)
+
+	labeled.MustRegister(Metrics.Revenue)
+	labeled.MustRegister(Metrics.Costs)
+	labeled.MustRegister(Metrics.Fee)
+	labeled.MustRegister(Metrics.IsAttractionClosed)
+	labeled.MustRegister(Metrics.AttractionAttempts)
+	labeled.MustRegister(Metrics.SettingsReloads)
+	labeled.MustRegister(Metrics.IsBroken)
+	labeled.MustRegister(Metrics.Uptime)
+	labeled.MustRegister(Metrics.Dirtiness)
+	labeled.MustRegister(Metrics.Maintenance)
+	labeled.MustRegister(Metrics.Upkeep)
+	labeled.MustRegister(Metrics.RideDuration)
+	labeled.MustRegister(Metrics.ServeDuration)
 }
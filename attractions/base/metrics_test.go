@@ -0,0 +1,38 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegisterAttractionMetricsLabels(t *testing.T) {
+	r := prometheus.NewRegistry()
+	RegisterAttractionMetrics(r, &Config{Name: "carousel", Instance: "carousel-1"})
+	Metrics.Revenue.Add(0)
+
+	families, err := r.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	if len(families) == 0 {
+		t.Fatal("Gather() returned no metrics")
+	}
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if labels["attraction"] != "carousel" || labels["attraction_instance"] != "carousel-1" {
+				t.Errorf("%s has labels %v, want attraction and attraction_instance", family.GetName(), labels)
+			}
+			// Prometheus reserves instance for the scrape target
+			if _, ok := labels["instance"]; ok {
+				t.Errorf("%s has the reserved label instance", family.GetName())
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/logger"
+)
+
+const (
+	// parkInterval is how many seconds pass between asking the park for its status
+	parkInterval = 10
+
+	// parkTimeout is how long each of the attraction's calls to the park may take,
+	// so a park that stopped answering, e.g. while a standby takes over, doesn't hold them up
+	parkTimeout = 5 * time.Second
+)
+
+// ParkStatus keeps track of the park the attraction is in
+type ParkStatus struct {
+	mu    sync.RWMutex
+	park  httptypes.Park
+	known bool
+}
+
+// Get returns the last known park status, and whether it is known yet
+func (p *ParkStatus) Get() (httptypes.Park, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.park, p.known
+}
+
+// Weather returns the last known weather condition, empty if it is unknown
+func (p *ParkStatus) Weather() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.park.Weather
+}
+
+// Refresh asks the park for its current status, and stamps logs with the park's simulated time
+func (p *ParkStatus) Refresh(ctx context.Context, parkURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parkURL+"/park-status", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := parkClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get park status: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("park status check failed with status: %d", resp.StatusCode)
+	}
+
+	var park httptypes.Park
+	if err := json.NewDecoder(resp.Body).Decode(&park); err != nil {
+		return fmt.Errorf("failed to decode park status: %v", err)
+	}
+
+	logger.SetSimTime(park.Time)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.park = park
+	p.known = true
+	return nil
+}
+
+// fetchCalendar asks the park for the two weeks of days from the day t falls on
+func fetchCalendar(ctx context.Context, parkURL string, t time.Time) ([]httptypes.CalendarDay, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parkURL+"/calendar?from="+url.QueryEscape(t.Format(time.RFC3339)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := parkClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get park calendar: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("park calendar request failed with status: %d", resp.StatusCode)
+	}
+
+	var days []httptypes.CalendarDay
+	if err := json.NewDecoder(resp.Body).Decode(&days); err != nil {
+		return nil, fmt.Errorf("failed to decode park calendar: %v", err)
+	}
+	return days, nil
+}
@@ -0,0 +1,45 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"kubepark/pkg/httptypes"
+)
+
+// status returns what guests, staff and the park need to know about the attraction
+func status(config *Config, state *StateManager, park *ParkStatus) httptypes.Attraction {
+	return httptypes.Attraction{
+		Name:     config.Name,
+		Instance: config.Instance,
+		URL:      config.URL,
+		Fee:      state.GetSettings().Fee,
+		Size:     config.Size,
+
+		Closed:    closedReason(config, state, park) != "",
+		Broken:    state.IsBroken(),
+		Dirtiness: state.GetDirtiness(),
+	}
+}
+
+// Heartbeat registers the attraction with the park's directory, or tells the park it's still there
+func Heartbeat(ctx context.Context, config *Config, state *StateManager, park *ParkStatus) error {
+	data, err := json.Marshal(status(config, state, park))
+	if err != nil {
+		return err
+	}
+
+	resp, err := sendSigned(ctx, config, state, "/attractions", data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("heartbeat failed with status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
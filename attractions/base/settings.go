@@ -0,0 +1,40 @@
+package base
+
+import (
+	"fmt"
+
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/settings"
+)
+
+// flagSettings returns the settings given on the command line, or their defaults
+func flagSettings(config *Config) httptypes.AttractionSettings {
+	return httptypes.AttractionSettings{
+		Fee:    config.Fee,
+		Closed: config.Closed,
+	}
+}
+
+// validateSettings checks that the attraction can run with the settings
+func validateSettings(s httptypes.AttractionSettings) error {
+	if s.Fee < 0 {
+		return fmt.Errorf("fee must not be negative")
+	}
+	return nil
+}
+
+// newSettingsApplier returns what applies the attraction's settings file on top of its flags
+func newSettingsApplier(config *Config, state *StateManager) *settings.Applier[httptypes.AttractionSettings] {
+	return &settings.Applier[httptypes.AttractionSettings]{
+		Component: "attraction",
+		Flags: func() httptypes.AttractionSettings {
+			return flagSettings(config)
+		},
+		Validate: validateSettings,
+		Store:    state.ApplySettings,
+		Applied: func(configured httptypes.AttractionSettings) {
+			Metrics.Fee.Set(configured.Fee)
+		},
+		Reloads: Metrics.SettingsReloads,
+	}
+}
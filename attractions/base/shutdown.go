@@ -0,0 +1,94 @@
+package base
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// drainTimeout is how long rides in progress get to finish when the attraction shuts down,
+// within the pod's termination grace period
+const drainTimeout = 20 * time.Second
+
+// rides keeps track of the rides in progress so they can be interrupted on shutdown
+type rides struct {
+	wg          sync.WaitGroup
+	interrupted chan struct{}
+
+	mu       sync.Mutex
+	stopping bool // Whether rides were interrupted, after which no ride may begin
+}
+
+// newRides creates a new ride tracker
+func newRides() *rides {
+	return &rides{
+		interrupted: make(chan struct{}),
+	}
+}
+
+// begin marks the start of a ride, and returns false once rides were interrupted
+func (r *rides) begin() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopping {
+		return false
+	}
+	r.wg.Add(1)
+	return true
+}
+
+// end marks the end of a ride, finished or interrupted
+func (r *rides) end() {
+	r.wg.Done()
+}
+
+// Interrupted is closed when rides in progress have to stop
+func (r *rides) Interrupted() <-chan struct{} {
+	return r.interrupted
+}
+
+// interrupt stops the rides in progress and waits for them to refund their guests.
+// Rides can't begin anymore once it was called.
+func (r *rides) interrupt() {
+	r.mu.Lock()
+	if !r.stopping {
+		r.stopping = true
+		close(r.interrupted)
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+// Stop drains the rides in progress, interrupting and refunding those that don't finish in time,
+// abandons repairs and cleaning in progress, stops both HTTP servers and saves the final state
+func (a *Attraction) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	// Staff come back for the job later rather than holding up the drain
+	a.stopMaintenance()
+
+	var errs []error
+	if err := a.MainServer.Shutdown(ctx); err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			errs = append(errs, err)
+		}
+		slog.Warn("Rides didn't finish in time, interrupting them")
+		a.rides.interrupt()
+	}
+	if err := a.MetricsServer.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := a.State.Flush(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := a.shutdownTracing(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
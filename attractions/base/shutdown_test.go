@@ -0,0 +1,112 @@
+package base
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRidesInterruptWaitsForRides(t *testing.T) {
+	r := newRides()
+	if !r.begin() {
+		t.Fatal("begin() = false before rides were interrupted")
+	}
+
+	// The ride is cut short once interrupted, and ends after refunding its guest
+	ended := make(chan struct{})
+	go func() {
+		<-r.Interrupted()
+		time.Sleep(10 * time.Millisecond)
+		close(ended)
+		r.end()
+	}()
+
+	r.interrupt()
+	select {
+	case <-ended:
+	default:
+		t.Fatal("interrupt() returned before the ride in progress ended")
+	}
+}
+
+func TestRidesBeginAfterInterrupt(t *testing.T) {
+	r := newRides()
+	r.interrupt()
+
+	if r.begin() {
+		t.Error("begin() = true after rides were interrupted")
+	}
+
+	// Interrupting again doesn't close the channel twice
+	r.interrupt()
+}
+
+func TestRidesBeginDuringInterrupt(t *testing.T) {
+	r := newRides()
+	if !r.begin() {
+		t.Fatal("begin() = false before rides were interrupted")
+	}
+
+	// Guests keep arriving while the rides are drained, and every ride that begins ends
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r.begin() {
+				<-r.Interrupted()
+				r.end()
+			}
+		}()
+	}
+
+	go func() {
+		<-r.Interrupted()
+		r.end()
+	}()
+
+	r.interrupt()
+	wg.Wait()
+
+	if r.begin() {
+		t.Error("begin() = true after rides were interrupted")
+	}
+}
+
+func TestSyncParkGivesUpOnShutdown(t *testing.T) {
+	// A park that stopped answering, e.g. while a standby takes over
+	hung := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hung
+	}))
+	defer server.Close()
+	defer close(hung)
+
+	state, err := NewStateManager("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Attraction{
+		Config: &Config{Name: "carousel", Instance: "carousel-1", ParkURL: server.URL},
+		State:  state,
+		Park:   &ParkStatus{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	synced := make(chan struct{})
+	go func() {
+		a.syncPark(ctx)
+		close(synced)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-synced:
+	case <-time.After(time.Second):
+		t.Fatal("syncPark() kept waiting on the park after shutting down")
+	}
+}
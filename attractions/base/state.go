@@ -1,13 +1,28 @@
 package base
 
 import (
+	"time"
+
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/settings"
 	"kubepark/pkg/state"
 )
 
+// maxAuditEntries is the number of settings changes kept in an attraction's state
+const maxAuditEntries = 100
+
 // AttractionState represents the persistent state of an attraction
 type AttractionState struct {
-	IsPurchased bool `json:"is_purchased"`
-	IsBroken    bool `json:"is_broken"`
+	IsPurchased bool    `json:"is_purchased"`
+	IsBroken    bool    `json:"is_broken"`
+	ParkKey     string  `json:"park_key"`  // Key for signing transactions with the park
+	Dirtiness   float64 `json:"dirtiness"` // From 0 for spotless to 1 for too dirty to use
+
+	UpkeepPaidUntil time.Time `json:"upkeep_paid_until"` // Simulated hour the upkeep has been paid until
+
+	Settings       httptypes.AttractionSettings `json:"settings"`        // Settings applied last
+	SettingsSource string                       `json:"settings_source"` // Where the applied settings came from
+	Audit          []httptypes.AuditEntry       `json:"audit"`           // Changes to the settings
 }
 
 // StateManager manages the attraction's persistent state
@@ -32,19 +47,29 @@ func NewStateManager(volumePath string) (*StateManager, error) {
 	}, nil
 }
 
+// Flush saves the state to disk, e.g. before shutting down
+func (s *StateManager) Flush() error {
+	return s.manager.Flush()
+}
+
 func (s *StateManager) set(setter func(*AttractionState)) error {
-	state := s.manager.Get().(*AttractionState)
-	setter(state)
-	return s.manager.Set(state)
+	return s.manager.Update(func(state interface{}) {
+		setter(state.(*AttractionState))
+	})
 }
 
-func (s *StateManager) get() *AttractionState {
-	return s.manager.Get().(*AttractionState)
+func (s *StateManager) view(viewer func(*AttractionState)) {
+	s.manager.View(func(state interface{}) {
+		viewer(state.(*AttractionState))
+	})
 }
 
 // IsPurchased returns whether the attraction has been purchased
-func (s *StateManager) IsPurchased() bool {
-	return s.get().IsPurchased
+func (s *StateManager) IsPurchased() (purchased bool) {
+	s.view(func(state *AttractionState) {
+		purchased = state.IsPurchased
+	})
+	return purchased
 }
 
 // SetPurchased sets whether the attraction has been purchased
@@ -55,8 +80,11 @@ func (s *StateManager) SetPurchased(purchased bool) error {
 }
 
 // IsBroken returns whether the attraction is broken
-func (s *StateManager) IsBroken() bool {
-	return s.get().IsBroken
+func (s *StateManager) IsBroken() (broken bool) {
+	s.view(func(state *AttractionState) {
+		broken = state.IsBroken
+	})
+	return broken
 }
 
 // SetBroken sets whether the attraction is broken
@@ -65,3 +93,87 @@ func (s *StateManager) SetBroken(broken bool) error {
 		state.IsBroken = broken
 	})
 }
+
+// GetDirtiness returns how dirty the attraction is
+func (s *StateManager) GetDirtiness() (dirtiness float64) {
+	s.view(func(state *AttractionState) {
+		dirtiness = state.Dirtiness
+	})
+	return dirtiness
+}
+
+// AddDirt makes the attraction dirtier, up to too dirty to use
+func (s *StateManager) AddDirt(dirt float64) error {
+	return s.set(func(state *AttractionState) {
+		state.Dirtiness = min(state.Dirtiness+dirt, 1)
+	})
+}
+
+// Clean makes the attraction spotless
+func (s *StateManager) Clean() error {
+	return s.set(func(state *AttractionState) {
+		state.Dirtiness = 0
+	})
+}
+
+// GetUpkeepPaidUntil returns the simulated hour the upkeep has been paid until
+func (s *StateManager) GetUpkeepPaidUntil() (paidUntil time.Time) {
+	s.view(func(state *AttractionState) {
+		paidUntil = state.UpkeepPaidUntil
+	})
+	return paidUntil
+}
+
+// SetUpkeepPaidUntil sets the simulated hour the upkeep has been paid until
+func (s *StateManager) SetUpkeepPaidUntil(hour time.Time) error {
+	return s.set(func(state *AttractionState) {
+		state.UpkeepPaidUntil = hour
+	})
+}
+
+// GetParkKey returns the key for signing transactions with the park
+func (s *StateManager) GetParkKey() (key string) {
+	s.view(func(state *AttractionState) {
+		key = state.ParkKey
+	})
+	return key
+}
+
+// SetParkKey sets the key for signing transactions with the park
+func (s *StateManager) SetParkKey(key string) error {
+	return s.set(func(state *AttractionState) {
+		state.ParkKey = key
+	})
+}
+
+// GetSettings returns the settings the attraction runs with
+func (s *StateManager) GetSettings() (current httptypes.AttractionSettings) {
+	s.view(func(state *AttractionState) {
+		current = state.Settings
+	})
+	return current
+}
+
+// GetSettingsStatus returns the settings the attraction runs with and how they changed
+func (s *StateManager) GetSettingsStatus() (status httptypes.AttractionSettingsResponse) {
+	s.view(func(state *AttractionState) {
+		status = httptypes.AttractionSettingsResponse{
+			Settings: state.Settings,
+			Source:   state.SettingsSource,
+			Audit:    append([]httptypes.AuditEntry{}, state.Audit...),
+		}
+	})
+	return status
+}
+
+// ApplySettings replaces the attraction's settings and records what changed in the audit log
+func (s *StateManager) ApplySettings(configured httptypes.AttractionSettings, source string) (changes []httptypes.AuditEntry, err error) {
+	err = s.set(func(state *AttractionState) {
+		changes = settings.Diff(source, time.Time{}, state.Settings, configured)
+		state.Settings = configured
+		state.SettingsSource = source
+
+		state.Audit = settings.Audit(state.Audit, changes, maxAuditEntries)
+	})
+	return changes, err
+}
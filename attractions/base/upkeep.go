@@ -0,0 +1,101 @@
+package base
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+// upkeepRate returns what the attraction costs to keep per simulated hour
+func upkeepRate(config *Config, open bool) float64 {
+	if open {
+		return config.UpkeepOpen
+	}
+	return config.UpkeepClosed
+}
+
+// isOperating returns whether the attraction is open to guests of an open park
+func isOperating(config *Config, state *StateManager, park *ParkStatus) bool {
+	status, _ := park.Get()
+	return !status.IsClosed && !state.IsBroken() && closedReason(config, state, park) == ""
+}
+
+// payUpkeep charges the park the upkeep of every simulated hour that passed since it was last paid.
+// Hours the park was open in weather the attraction runs in are charged at the open rate and the
+// rest at the closed rate. Whether the attraction itself is closed or broken is only known for now,
+// so that holds for all the hours.
+func payUpkeep(ctx context.Context, config *Config, state *StateManager, park *ParkStatus) error {
+	status, ok := park.Get()
+	if !ok || status.Time.IsZero() {
+		return nil
+	}
+
+	hour := status.Time.Truncate(time.Hour)
+	paidUntil := state.GetUpkeepPaidUntil()
+
+	// Start paying from the first hour the attraction sees, and again if the park's clock went back
+	if paidUntil.IsZero() || hour.Before(paidUntil) {
+		return state.SetUpkeepPaidUntil(hour)
+	}
+	if !hour.After(paidUntil) {
+		return nil
+	}
+
+	open := 0.0
+	if !state.IsBroken() && !state.GetSettings().Closed {
+		var err error
+		if open, err = openHours(ctx, config, paidUntil, hour); err != nil {
+			return err
+		}
+	}
+
+	closed := hour.Sub(paidUntil).Hours() - open
+	if amount := open*config.UpkeepOpen + closed*config.UpkeepClosed; amount > 0 {
+		if err := ParkTransaction(ctx, config, state, httptypes.CategoryUpkeep, -amount); err != nil {
+			return err
+		}
+	}
+
+	return state.SetUpkeepPaidUntil(hour)
+}
+
+// openHours returns how many hours between from and until the park was open in weather the attraction runs in
+func openHours(ctx context.Context, config *Config, from time.Time, until time.Time) (float64, error) {
+	hours := 0.0
+	for next := from; next.Before(until); {
+		days, err := fetchCalendar(ctx, config.ParkURL, next)
+		if err != nil {
+			return 0, err
+		}
+		if len(days) == 0 {
+			break
+		}
+
+		for _, day := range days {
+			if day.Closed || slices.Contains(config.ClosedInWeather, day.Weather) {
+				continue
+			}
+			hours += overlap(day.Opens, day.Closes, from, until).Hours()
+		}
+
+		// Days are shorter than a day, so a day after the last opening falls on the next day
+		next = days[len(days)-1].Opens.AddDate(0, 0, 1)
+	}
+	return hours, nil
+}
+
+// overlap returns how long the time from start to end overlaps the time from from to until
+func overlap(start time.Time, end time.Time, from time.Time, until time.Time) time.Duration {
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(until) {
+		end = until
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
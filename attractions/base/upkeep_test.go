@@ -0,0 +1,82 @@
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+func TestOverlap(t *testing.T) {
+	day := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	opens, closes := day.Add(9*time.Hour), day.Add(17*time.Hour)
+
+	tests := []struct {
+		name  string
+		from  time.Time
+		until time.Time
+		want  time.Duration
+	}{
+		{"whole day", day, day.Add(24 * time.Hour), 8 * time.Hour},
+		{"morning", day, day.Add(11 * time.Hour), 2 * time.Hour},
+		{"within opening hours", day.Add(10 * time.Hour), day.Add(12 * time.Hour), 2 * time.Hour},
+		{"after closing", day.Add(18 * time.Hour), day.Add(20 * time.Hour), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := overlap(opens, closes, tt.from, tt.until); got != tt.want {
+				t.Errorf("overlap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenHours(t *testing.T) {
+	first := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	calendar := []httptypes.CalendarDay{
+		{Date: "2025-06-01", Opens: first.Add(9 * time.Hour), Closes: first.Add(17 * time.Hour), Weather: "sunny"},
+		{Date: "2025-06-02", Opens: first.Add(33 * time.Hour), Closes: first.Add(41 * time.Hour), Weather: "stormy"},
+		{Date: "2025-06-03", Opens: first.Add(57 * time.Hour), Closes: first.Add(65 * time.Hour), Weather: "cloudy", Closed: true},
+	}
+
+	// The park returns the days opening from the given time on
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, err := time.Parse(time.RFC3339, r.URL.Query().Get("from"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		days := []httptypes.CalendarDay{}
+		for _, day := range calendar {
+			if day.Closes.After(from) {
+				days = append(days, day)
+			}
+		}
+		json.NewEncoder(w).Encode(days)
+	}))
+	defer server.Close()
+
+	config := &Config{ParkURL: server.URL, ClosedInWeather: []string{"stormy"}}
+
+	// Only the sunny day counts, the stormy day is closed for the attraction and the last for the park
+	hours, err := openHours(context.Background(), config, first, first.Add(72*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hours != 8 {
+		t.Errorf("openHours() = %v, want 8", hours)
+	}
+
+	hours, err = openHours(context.Background(), config, first.Add(12*time.Hour), first.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hours != 5 {
+		t.Errorf("openHours() from noon = %v, want 5", hours)
+	}
+}
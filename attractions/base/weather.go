@@ -0,0 +1,16 @@
+package base
+
+import (
+	"slices"
+)
+
+// closedReason returns why the attraction is closed, empty if it is open
+func closedReason(config *Config, state *StateManager, park *ParkStatus) string {
+	if state.GetSettings().Closed {
+		return "attraction_closed"
+	}
+	if slices.Contains(config.ClosedInWeather, park.Weather()) {
+		return "weather_closed"
+	}
+	return ""
+}
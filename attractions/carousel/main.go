@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"kubepark/attractions/base"
+	"kubepark/pkg/httptypes"
 )
 
 // Carousel represents a carousel attraction
@@ -15,11 +20,17 @@ type Carousel struct {
 // New creates a new carousel attraction
 func New() *Carousel {
 	config := &base.Config{
-		Name:       "carousel",
-		Duration:   3 * time.Second,
-		BuildCost:  20000,
-		RepairCost: 1000,
-		Size:       10,
+		Name:            "carousel",
+		Duration:        3 * time.Second,
+		BuildCost:       20000,
+		RepairCost:      1000,
+		Size:            10,
+		UpkeepOpen:      50,
+		UpkeepClosed:    10,
+		ClosedInWeather: []string{httptypes.WeatherStorm},
+		RepairDuration:  20 * time.Second,
+		CleanDuration:   5 * time.Second,
+		DirtPerUse:      0.01,
 	}
 
 	defaultFee := 5.0
@@ -36,8 +47,13 @@ func afterUse() error {
 
 func main() {
 	carousel := New()
+
+	// Stop on SIGTERM, letting rides in progress finish first
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
 	slog.Info("Starting carousel attraction", "park_url", carousel.Config.ParkURL)
-	if err := carousel.Start(); err != nil {
+	if err := carousel.Start(ctx); err != nil {
 		slog.Error("Carousel attraction failed to start", "error", err)
 		panic(err)
 	}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"kubepark/attractions/base"
@@ -15,11 +19,16 @@ type Restroom struct {
 // New creates a new restroom attraction
 func New() *Restroom {
 	config := &base.Config{
-		Name:       "restroom",
-		Duration:   2 * time.Second,
-		BuildCost:  10000,
-		RepairCost: 500,
-		Size:       1,
+		Name:           "restroom",
+		Duration:       2 * time.Second,
+		BuildCost:      10000,
+		RepairCost:     500,
+		Size:           1,
+		UpkeepOpen:     10,
+		UpkeepClosed:   2,
+		RepairDuration: 10 * time.Second,
+		CleanDuration:  10 * time.Second,
+		DirtPerUse:     0.05,
 	}
 
 	defaultFee := 2.0
@@ -36,8 +45,13 @@ func afterUse() error {
 
 func main() {
 	restroom := New()
+
+	// Stop on SIGTERM, letting rides in progress finish first
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
 	slog.Info("Starting restroom attraction", "park_url", restroom.Config.ParkURL)
-	if err := restroom.Start(); err != nil {
+	if err := restroom.Start(ctx); err != nil {
 		slog.Error("Restroom attraction failed to start", "error", err)
 		panic(err)
 	}
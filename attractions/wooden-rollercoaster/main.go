@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"kubepark/attractions/base"
+	"kubepark/pkg/httptypes"
 )
 
 // WoodenRollercoaster represents a wooden rollercoaster attraction
@@ -15,11 +20,17 @@ type WoodenRollercoaster struct {
 // New creates a new wooden rollercoaster attraction
 func New() *WoodenRollercoaster {
 	config := &base.Config{
-		Name:       "wooden-rollercoaster",
-		Duration:   45 * time.Second,
-		BuildCost:  150000,
-		RepairCost: 5000,
-		Size:       25, // Large footprint for a rollercoaster
+		Name:            "wooden-rollercoaster",
+		Duration:        45 * time.Second,
+		BuildCost:       150000,
+		RepairCost:      5000,
+		Size:            25, // Large footprint for a rollercoaster
+		UpkeepOpen:      400,
+		UpkeepClosed:    100,
+		ClosedInWeather: []string{httptypes.WeatherStorm},
+		RepairDuration:  time.Minute,
+		CleanDuration:   10 * time.Second,
+		DirtPerUse:      0.01,
 	}
 
 	defaultFee := 15.0 // Higher fee for thrilling attraction
@@ -36,8 +47,13 @@ func afterUse() error {
 
 func main() {
 	woodenRollercoaster := New()
+
+	// Stop on SIGTERM, letting rides in progress finish first
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
 	slog.Info("Starting wooden rollercoaster attraction", "park_url", woodenRollercoaster.Config.ParkURL)
-	if err := woodenRollercoaster.Start(); err != nil {
+	if err := woodenRollercoaster.Start(ctx); err != nil {
 		slog.Error("Wooden rollercoaster attraction failed to start", "error", err)
 		panic(err)
 	}
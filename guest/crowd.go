@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/tracing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// Crowd metrics, on top of the guest metrics summed over all guests of the crowd
+	CrowdGuests = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "crowd_guests",
+		Help: "Number of guests of the crowd visiting the park",
+	})
+
+	CrowdVisits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "crowd_visits_total",
+			Help: "Number of visits by guests of the crowd",
+		},
+		[]string{"result"},
+	)
+)
+
+// Crowd simulates many guests at once in a single pod, each visiting the park in its own goroutine
+type Crowd struct {
+	parkURL string
+	size    int
+
+	mu       sync.Mutex
+	guests   map[*Guest]context.CancelFunc // Guests visiting the park and how to send them home
+	finished int
+	failed   int
+	wg       sync.WaitGroup
+}
+
+// NewCrowd creates a crowd of at most size guests at once
+func NewCrowd(parkURL string, size int) *Crowd {
+	return &Crowd{
+		parkURL: parkURL,
+		size:    size,
+		guests:  make(map[*Guest]context.CancelFunc),
+	}
+}
+
+// Join sends a new guest into the park until it leaves or the context is done, unless the crowd is full
+func (c *Crowd) Join(ctx context.Context, money float64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.guests) >= c.size {
+		return false
+	}
+
+	guest := NewGuest(c.parkURL, money)
+	ctx, cancel := context.WithCancel(ctx)
+	c.guests[guest] = cancel
+	CrowdGuests.Set(float64(len(c.guests)))
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer cancel()
+
+		err := guest.Visit(ctx)
+		if err != nil {
+			guest.log.Warn("Guest couldn't visit the park", "error", err)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.guests, guest)
+		CrowdGuests.Set(float64(len(c.guests)))
+		if err != nil {
+			c.failed++
+			CrowdVisits.WithLabelValues("failed").Inc()
+		} else {
+			c.finished++
+			CrowdVisits.WithLabelValues("finished").Inc()
+		}
+	}()
+
+	return true
+}
+
+// SendHome asks every guest of the crowd to leave the park, and returns how many were asked
+func (c *Crowd) SendHome() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, cancel := range c.guests {
+		cancel()
+	}
+	return len(c.guests)
+}
+
+// Wait waits for every guest of the crowd to leave the park
+func (c *Crowd) Wait() {
+	c.wg.Wait()
+}
+
+// Status returns how many guests of the crowd are visiting and have visited the park
+func (c *Crowd) Status() httptypes.CrowdStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return httptypes.CrowdStatus{
+		Size:     c.size,
+		Active:   len(c.guests),
+		Finished: c.finished,
+		Failed:   c.failed,
+	}
+}
+
+// handleGuests handles the park sending guests into the park and asking how the crowd is doing
+func handleGuests(ctx context.Context, crowd *Crowd) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var req httptypes.CrowdGuest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if req.Money < 0 {
+				http.Error(w, "Money must not be negative", http.StatusBadRequest)
+				return
+			}
+
+			// Guests outlive the request that sent them, but not the crowd
+			if !crowd.Join(ctx, req.Money) {
+				http.Error(w, "Crowd is full", http.StatusServiceUnavailable)
+				return
+			}
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(crowd.Status())
+	}
+}
+
+// handleSendHome handles the park asking every guest of the crowd to leave
+func handleSendHome(crowd *Crowd) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		sent := crowd.SendHome()
+		if sent > 0 {
+			slog.Info("Sent crowd home", "guests", sent)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(httptypes.CrowdDismissal{SentHome: sent})
+	}
+}
+
+// runCrowd serves the crowd on port 80 until the context is done, then waits for every guest to leave
+func runCrowd(ctx context.Context, crowd *Crowd) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/guests", handleGuests(ctx, crowd))
+	mux.HandleFunc("/guests/leave", handleSendHome(crowd))
+	server := &http.Server{
+		Addr:    ":80",
+		Handler: tracing.Handler(mux, "guest-crowd"),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting crowd server on port 80", "size", crowd.size)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Sending the crowd home", "guests", crowd.Status().Active)
+	err := server.Close()
+	crowd.Wait()
+	return err
+}
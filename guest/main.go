@@ -1,15 +1,16 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
-	"io"
-	"kubepark/pkg/k8s"
+	"kubepark/pkg/constants"
 	"kubepark/pkg/logger"
+	"kubepark/pkg/tracing"
 	"log/slog"
-	"math/rand"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -29,18 +30,14 @@ var (
 
 	// Configuration
 	config struct {
-		ParkURL  string
-		Money    float64
-		LogLevel string
+		ParkURL   string
+		Money     float64
+		LogLevel  string
+		LogFormat string
+		CrowdSize int
 	}
 )
 
-// Attraction represents an attraction in the park
-type Attraction struct {
-	URL string  `json:"url"`
-	Fee float64 `json:"fee"`
-}
-
 func main() {
 	// Register metrics
 	r := prometheus.NewRegistry()
@@ -49,113 +46,66 @@ func main() {
 
 	// Parse flags
 	flag.StringVar(&config.ParkURL, "park-url", "http://kubepark:80", "URL of the kubepark service")
+	flag.Float64Var(&config.Money, "money", constants.GuestMoney, "Money the guest brings to the park")
 	flag.StringVar(&config.LogLevel, "log-level", "info", "Log level (debug, info, warn, error)")
+	flag.StringVar(&config.LogFormat, "log-format", logger.FormatText, "Log format (text, json)")
+	flag.IntVar(&config.CrowdSize, "crowd-size", 0, "Simulate a crowd of up to this many guests sent in by the park, instead of a single guest")
 	flag.Parse()
 
-	// Initialize logger with configured level
-	logger.InitLogger(config.LogLevel)
+	// Initialize logger with configured level and format. A crowd is one component
+	// logging for many guests, which tell themselves apart by guest_id.
+	component := "guest"
+	if config.CrowdSize > 0 {
+		component = "guest-crowd"
+	}
+	logger.Init(logger.Options{
+		Level:     config.LogLevel,
+		Format:    config.LogFormat,
+		Component: component,
+		Attrs:     []any{"instance", os.Getenv("HOSTNAME")},
+	})
 
-	// Set fixed values
-	config.Money = 100 // Each guest starts with $100
+	if config.CrowdSize > 0 {
+		r.MustRegister(CrowdGuests)
+		r.MustRegister(CrowdVisits)
+	}
+
+	// Trace visits through the park, and send the spans left before exiting
+	shutdownTracing, err := tracing.Init(context.Background(), "guest")
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "error", err)
+		panic(err)
+	}
+	defer func() {
+		if err := shutdownTracing(); err != nil {
+			slog.Warn("Failed to send last spans", "error", err)
+		}
+	}()
 
 	// Start metrics server
 	go func() {
 		slog.Info("Starting metrics server on port 9000")
 		http.Handle("/metrics", promhttp.HandlerFor(r, promhttp.HandlerOpts{}))
+		http.HandleFunc("/log-level", logger.HandleLevel())
 		if err := http.ListenAndServe(":9000", nil); err != nil {
 			slog.Error("Metrics server failed", "error", err)
 			panic(err)
 		}
 	}()
 
-	// Try to enter the park
-	slog.Info("Entering park")
-	if err := enterPark(); err != nil {
-		slog.Error("Failed to enter park", "error", err)
-		return
-	}
-
-	// Start exploring attractions
-	slog.Info("Starting attraction loop")
-	for {
-		// Visit a random attraction
-		if err := visitAttraction(); err != nil {
-			slog.Warn("Failed to visit attraction", "error", err)
-		}
+	// Cut the visit short on SIGTERM, but still say goodbye to the park
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
-		// Random chance (30%) that guest decides to leave early
-		if rand.Float64() < 0.30 {
-			slog.Info("Guest decided to leave early")
-			break
+	if config.CrowdSize > 0 {
+		if err := runCrowd(ctx, NewCrowd(config.ParkURL, config.CrowdSize)); err != nil && err != http.ErrServerClosed {
+			slog.Error("Crowd failed", "error", err)
+			panic(err)
 		}
-
-		// Take a break between attractions
-		time.Sleep(time.Duration(rand.Intn(30)+30) * time.Second)
-	}
-
-	slog.Info("Guest finished their visit.")
-}
-
-func enterPark() error {
-	// Make request to enter park
-	resp, err := http.Post(config.ParkURL+"/enter", "application/json", nil)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to enter park: %s", resp.Status)
-	}
-
-	slog.Info("Successfully entered the park")
-	return nil
-}
-
-func visitAttraction() error {
-	// Get list of available attractions using Kubernetes API
-	attractions, err := k8s.DiscoverAttractions()
-	if err != nil {
-		return fmt.Errorf("failed to discover attractions: %v", err)
-	}
-
-	if len(attractions) == 0 {
-		return fmt.Errorf("no attractions available")
-	}
-
-	// Choose a random attraction
-	randAttraction := attractions[rand.Intn(len(attractions))]
-
-	// Check if guest has enough money
-	if config.Money < randAttraction.Fee {
-		return fmt.Errorf("insufficient funds. Fee is $%.2f but guest has $%.2f", randAttraction.Fee, config.Money)
-	}
-
-	// Visit the attraction
-	resp, err := http.Post(fmt.Sprintf("%s/use", randAttraction.URL), "application/json", nil)
-	if err != nil {
-		return err
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		// Read the error message from the response body
-		body, readErr := io.ReadAll(resp.Body)
-		if readErr != nil {
-			return fmt.Errorf("failed to use attraction %s: %s", randAttraction.URL, resp.Status)
-		}
-		errorMessage := string(body)
-		if errorMessage == "" {
-			errorMessage = resp.Status
-		}
-		return fmt.Errorf("failed to use attraction %s: %s", randAttraction.URL, errorMessage)
+	if err := NewGuest(config.ParkURL, config.Money).Visit(ctx); err != nil {
+		slog.Error("Failed to visit park", "error", err)
 	}
-
-	// Update metrics and money
-	MoneySpent.Add(randAttraction.Fee)
-	AttractionsVisited.Inc()
-	config.Money -= randAttraction.Fee
-
-	slog.Info("Visited attraction", "url", randAttraction.URL, "fee", randAttraction.Fee)
-	return nil
 }
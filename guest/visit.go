@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kubepark/pkg/directory"
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/tracing"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// client passes the trace of the guest's visit on to the park and its attractions
+var client = tracing.NewClient(0)
+
+// Guest is a single visitor to the park, whether it has a pod to itself or is one of a crowd
+type Guest struct {
+	ParkURL string
+	Money   float64 // Money the guest has left
+	ID      string  // ID the park gave the guest on entry
+
+	visit httptypes.VisitReport // What the guest tells the park about its visit when leaving
+	log   *slog.Logger
+}
+
+// NewGuest creates a guest that comes to the park with the given money
+func NewGuest(parkURL string, money float64) *Guest {
+	return &Guest{
+		ParkURL: parkURL,
+		Money:   money,
+		log:     slog.Default(),
+	}
+}
+
+// Visit enters the park and explores its attractions until the guest decides to leave or the
+// context is done. A guest that got in always reports on its visit and leaves through the exit.
+// The whole visit is one trace, so the guest's day can be followed through the park.
+func (g *Guest) Visit(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "guest.visit", attribute.Float64("guest.money", g.Money))
+	defer span.End()
+
+	// The guest still says goodbye to the park after being asked to leave
+	leave := context.WithoutCancel(ctx)
+
+	// Try to enter the park
+	g.log.InfoContext(ctx, "Entering park")
+	if err := g.enterPark(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "failed to enter park")
+		return fmt.Errorf("failed to enter park: %v", err)
+	}
+	span.SetAttributes(attribute.String("guest.id", g.ID))
+
+	// Start exploring attractions
+	g.log.InfoContext(ctx, "Starting attraction loop")
+	for {
+		// Visit a random attraction
+		if err := g.visitAttraction(ctx); err != nil {
+			g.log.WarnContext(ctx, "Failed to visit attraction", "error", err)
+		}
+
+		// Random chance (30%) that guest decides to leave early
+		if rand.Float64() < 0.30 {
+			g.log.InfoContext(ctx, "Guest decided to leave early")
+			break
+		}
+
+		// Take a break between attractions, unless the guest has to go
+		select {
+		case <-ctx.Done():
+			g.log.InfoContext(ctx, "Guest was asked to leave")
+		case <-time.After(time.Duration(rand.Intn(30)+30) * time.Second):
+			continue
+		}
+		break
+	}
+
+	// Tell the park how the visit went and that we're gone
+	if err := g.sendVisitReport(leave); err != nil {
+		g.log.WarnContext(leave, "Failed to send visit report", "error", err)
+	}
+	if err := g.leavePark(leave); err != nil {
+		g.log.WarnContext(leave, "Failed to leave park", "error", err)
+	}
+
+	g.log.InfoContext(leave, "Guest finished their visit.")
+	return nil
+}
+
+// post sends a JSON request to the park or one of its attractions as part of the guest's trace
+func post(ctx context.Context, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return client.Do(req)
+}
+
+func (g *Guest) enterPark(ctx context.Context) error {
+	// Make request to enter park
+	resp, err := post(ctx, g.ParkURL+"/enter", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to enter park: %s", resp.Status)
+	}
+
+	var entered httptypes.EnterResponse
+	if err := json.NewDecoder(resp.Body).Decode(&entered); err != nil {
+		return fmt.Errorf("failed to decode park entry: %v", err)
+	}
+	g.ID = entered.GuestID
+	g.log = g.log.With("guest_id", g.ID)
+
+	g.log.InfoContext(ctx, "Successfully entered the park")
+	return nil
+}
+
+func (g *Guest) leavePark(ctx context.Context) error {
+	data, err := json.Marshal(httptypes.ExitRequest{GuestID: g.ID})
+	if err != nil {
+		return err
+	}
+
+	resp, err := post(ctx, g.ParkURL+"/exit", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to leave park: %s", resp.Status)
+	}
+
+	g.log.InfoContext(ctx, "Left the park")
+	return nil
+}
+
+func (g *Guest) visitAttraction(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "guest.visitAttraction")
+	defer span.End()
+
+	// Get list of available attractions from the park's directory
+	attractions, err := directory.Attractions(ctx, g.ParkURL)
+	if err != nil {
+		g.visit.Failures = append(g.visit.Failures, "discovery_failed")
+		return fmt.Errorf("failed to discover attractions: %v", err)
+	}
+
+	if len(attractions) == 0 {
+		g.visit.Failures = append(g.visit.Failures, "no_attractions")
+		return fmt.Errorf("no attractions available")
+	}
+
+	// Choose a random attraction
+	randAttraction := attractions[rand.Intn(len(attractions))]
+
+	// Check if guest has enough money
+	if g.Money < randAttraction.Fee {
+		g.visit.Failures = append(g.visit.Failures, "insufficient_funds")
+		return fmt.Errorf("insufficient funds. Fee is $%.2f but guest has $%.2f", randAttraction.Fee, g.Money)
+	}
+
+	ride := httptypes.RideReport{
+		Attraction: randAttraction.Name,
+		Instance:   randAttraction.Instance,
+	}
+	span.SetAttributes(
+		attribute.String("attraction.name", randAttraction.Name),
+		attribute.String("attraction.instance", randAttraction.Instance),
+	)
+
+	// Visit the attraction
+	resp, err := post(ctx, fmt.Sprintf("%s/use", randAttraction.URL), nil)
+	if err != nil {
+		ride.Reason = "unreachable"
+		g.visit.Rides = append(g.visit.Rides, ride)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		ride.Reason = resp.Header.Get(httptypes.HeaderReason)
+		if ride.Reason == "" {
+			ride.Reason = "unknown"
+		}
+		g.visit.Rides = append(g.visit.Rides, ride)
+
+		// Read the error message from the response body
+		body, readErr := io.ReadAll(resp.Body)
+		if readErr != nil {
+			return fmt.Errorf("failed to use attraction %s: %s", randAttraction.URL, resp.Status)
+		}
+		errorMessage := string(body)
+		if errorMessage == "" {
+			errorMessage = resp.Status
+		}
+		return fmt.Errorf("failed to use attraction %s: %s", randAttraction.URL, errorMessage)
+	}
+
+	ride.Success = true
+	g.visit.Rides = append(g.visit.Rides, ride)
+
+	// Update metrics and money
+	MoneySpent.Add(randAttraction.Fee)
+	AttractionsVisited.Inc()
+	g.Money -= randAttraction.Fee
+
+	g.log.InfoContext(ctx, "Visited attraction", "url", randAttraction.URL, "fee", randAttraction.Fee)
+	return nil
+}
+
+// satisfaction rates the visit from 0 to 5 stars. Guests start out neutral, enjoy every
+// ride and are put off by everything that went wrong.
+func (g *Guest) satisfaction() float64 {
+	score := 3.0
+	for _, ride := range g.visit.Rides {
+		if ride.Success {
+			score += 0.5
+		} else {
+			score -= 1
+		}
+	}
+	score -= float64(len(g.visit.Failures))
+
+	return math.Max(0, math.Min(score, 5))
+}
+
+func (g *Guest) sendVisitReport(ctx context.Context) error {
+	g.visit.GuestID = g.ID
+	g.visit.MoneyLeft = g.Money
+	g.visit.Satisfaction = g.satisfaction()
+
+	data, err := json.Marshal(g.visit)
+	if err != nil {
+		return err
+	}
+
+	resp, err := post(ctx, g.ParkURL+"/visit-report", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to send visit report: %s", resp.Status)
+	}
+
+	g.log.InfoContext(ctx, "Sent visit report", "rides", len(g.visit.Rides), "failures", len(g.visit.Failures), "satisfaction", g.visit.Satisfaction)
+	return nil
+}
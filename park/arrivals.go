@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+// neutralReputation is the park rating at which reputation neither attracts nor deters guests
+const neutralReputation = 3.0
+
+// ArrivalContext is everything an arrival model may base the arrival rate on
+type ArrivalContext struct {
+	Time        time.Time
+	EntranceFee float64
+	Attractions []httptypes.Attraction
+	Reputation  float64 // Park rating from 0 to 5 stars
+}
+
+// ArrivalModel decides how many guests come to the park
+type ArrivalModel interface {
+	// Rate returns the expected number of guest arrivals per simulated hour
+	Rate(ctx ArrivalContext) float64
+}
+
+// FlatArrivalModel has guests arrive at a constant rate no matter what
+type FlatArrivalModel struct {
+	PerHour float64
+}
+
+// Rate returns the constant arrival rate
+func (m FlatArrivalModel) Rate(ctx ArrivalContext) float64 {
+	return m.PerHour
+}
+
+// DemandArrivalModel has guests arrive depending on the time of day, the entrance fee,
+// the attractions on offer and the park's reputation
+type DemandArrivalModel struct {
+	PeakRate       float64 // Arrivals per simulated hour at peak time in an ideal park
+	PeakHour       float64 // Hour of the day most guests arrive
+	PeakWidth      float64 // Spread of arrivals around the peak in hours
+	FeeSensitivity float64 // How strongly the entrance fee deters guests
+	GuestMoney     float64 // Money each guest brings
+}
+
+// Rate returns the arrival rate for the current state of the park
+func (m DemandArrivalModel) Rate(ctx ArrivalContext) float64 {
+	return m.PeakRate *
+		m.hourFactor(ctx.Time) *
+		m.feeFactor(ctx.EntranceFee) *
+		attractionFactor(ctx.Attractions) *
+		reputationFactor(ctx.Reputation)
+}
+
+// hourFactor peaks at the peak hour and never drops below a trickle of early and late guests
+func (m DemandArrivalModel) hourFactor(t time.Time) float64 {
+	hour := float64(t.Hour()) + float64(t.Minute())/60
+	distance := (hour - m.PeakHour) / m.PeakWidth
+	return 0.2 + 0.8*math.Exp(-distance*distance/2)
+}
+
+// feeFactor drops to zero as the entrance fee approaches what a guest can spend
+func (m DemandArrivalModel) feeFactor(fee float64) float64 {
+	share := math.Max(0, 1-fee/m.GuestMoney)
+	return math.Pow(share, m.FeeSensitivity)
+}
+
+// attractionFactor grows with the variety and number of attractions. Even an empty
+// park gets a few curious visitors.
+func attractionFactor(attractions []httptypes.Attraction) float64 {
+	types := make(map[string]bool)
+	for _, attraction := range attractions {
+		types[attraction.Name] = true
+	}
+
+	// Variety counts more than duplicates of the same attraction
+	appeal := float64(len(types)) + 0.25*float64(len(attractions)-len(types))
+	return 0.1 + 0.9*(1-math.Exp(-appeal/2))
+}
+
+// reputationFactor scales arrivals with the park's rating relative to a neutral one
+func reputationFactor(reputation float64) float64 {
+	return math.Max(0, math.Min(reputation, 5)) / neutralReputation
+}
+
+// NewArrivalModel creates the arrival model described by the guest parameters
+func NewArrivalModel(params GuestParams) (ArrivalModel, error) {
+	switch params.ArrivalModel {
+	case "flat":
+		return FlatArrivalModel{PerHour: params.FlatRate}, nil
+	case "demand":
+		if params.PeakWidth <= 0 {
+			return nil, fmt.Errorf("peak width must be positive")
+		}
+
+		return DemandArrivalModel{
+			PeakRate:       params.PeakRate,
+			PeakHour:       params.PeakHour,
+			PeakWidth:      params.PeakWidth,
+			FeeSensitivity: params.FeeSensitivity,
+			GuestMoney:     params.Money,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown arrival model %q", params.ArrivalModel)
+	}
+}
+
+// arrivals draws the number of guests arriving from a Poisson distribution with the given mean
+func arrivals(mean float64) int {
+	if mean <= 0 {
+		return 0
+	}
+
+	// Knuth's algorithm is plenty for the small means of a single tick
+	limit := math.Exp(-mean)
+	count := 0
+	for p := rand.Float64(); p > limit; p *= rand.Float64() {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+func TestDemandArrivalModelRate(t *testing.T) {
+	model := DemandArrivalModel{
+		PeakRate:       12,
+		PeakHour:       13,
+		PeakWidth:      3,
+		FeeSensitivity: 2,
+		GuestMoney:     100,
+	}
+	park := ArrivalContext{
+		Time:        time.Date(2025, 6, 1, 13, 0, 0, 0, time.UTC),
+		EntranceFee: 10,
+		Attractions: []httptypes.Attraction{{Name: "carousel"}},
+		Reputation:  neutralReputation,
+	}
+	peak := model.Rate(park)
+	if peak <= 0 {
+		t.Fatalf("Rate() at the peak = %v, want more than 0", peak)
+	}
+
+	tests := []struct {
+		name   string
+		change func(ctx *ArrivalContext)
+	}{
+		{"early in the morning", func(ctx *ArrivalContext) { ctx.Time = ctx.Time.Add(-8 * time.Hour) }},
+		{"higher entrance fee", func(ctx *ArrivalContext) { ctx.EntranceFee = 50 }},
+		{"no attractions", func(ctx *ArrivalContext) { ctx.Attractions = nil }},
+		{"bad reputation", func(ctx *ArrivalContext) { ctx.Reputation = 1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := park
+			tt.change(&ctx)
+			if got := model.Rate(ctx); got <= 0 || got >= peak {
+				t.Errorf("Rate() = %v, want fewer arrivals than %v but some", got, peak)
+			}
+		})
+	}
+
+	// Nobody comes when the entrance fee takes all of a guest's money
+	park.EntranceFee = 100
+	if got := model.Rate(park); got != 0 {
+		t.Errorf("Rate() with an entrance fee of all a guest's money = %v, want 0", got)
+	}
+}
+
+func TestAttractionFactor(t *testing.T) {
+	one := []httptypes.Attraction{{Name: "carousel"}}
+	duplicate := append(one, httptypes.Attraction{Name: "carousel"})
+	variety := append(one, httptypes.Attraction{Name: "wooden-rollercoaster"})
+
+	if attractionFactor(nil) <= 0 {
+		t.Errorf("attractionFactor(nil) = %v, want a few curious visitors", attractionFactor(nil))
+	}
+	if attractionFactor(duplicate) <= attractionFactor(one) {
+		t.Errorf("a second carousel doesn't draw more guests")
+	}
+	if attractionFactor(variety) <= attractionFactor(duplicate) {
+		t.Errorf("a different attraction draws no more guests than a duplicate")
+	}
+}
+
+func TestNewArrivalModel(t *testing.T) {
+	flat, err := NewArrivalModel(GuestParams{ArrivalModel: "flat", FlatRate: 3.6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := flat.Rate(ArrivalContext{}); got != 3.6 {
+		t.Errorf("flat Rate() = %v, want 3.6", got)
+	}
+
+	if _, err := NewArrivalModel(GuestParams{ArrivalModel: "demand"}); err == nil {
+		t.Errorf("NewArrivalModel() without a peak width succeeded, want an error")
+	}
+	if _, err := NewArrivalModel(GuestParams{ArrivalModel: "stampede"}); err == nil {
+		t.Errorf("NewArrivalModel() of an unknown model succeeded, want an error")
+	}
+}
+
+func TestArrivals(t *testing.T) {
+	if got := arrivals(0); got != 0 {
+		t.Errorf("arrivals(0) = %d, want 0", got)
+	}
+
+	// The draws average out to the mean
+	const mean, draws = 2.5, 20000
+	total := 0
+	for range draws {
+		total += arrivals(mean)
+	}
+	if got := float64(total) / draws; math.Abs(got-mean) > 0.1 {
+		t.Errorf("arrivals(%v) averages %v", mean, got)
+	}
+}
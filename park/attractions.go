@@ -0,0 +1,116 @@
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/k8s"
+)
+
+// attractionTimeout is how long an attraction may go without a heartbeat before it is dropped from the directory
+const attractionTimeout = 30 * time.Second
+
+// AttractionDirectory keeps track of the attractions in the park from the heartbeats they send
+type AttractionDirectory struct {
+	mu          sync.RWMutex
+	attractions map[string]httptypes.Attraction // Attractions by instance
+}
+
+// NewAttractionDirectory creates a new attraction directory
+func NewAttractionDirectory() *AttractionDirectory {
+	return &AttractionDirectory{
+		attractions: make(map[string]httptypes.Attraction),
+	}
+}
+
+// Heartbeat registers an attraction or updates what the directory knows about it,
+// and returns whether the attraction is new to the directory
+func (d *AttractionDirectory) Heartbeat(attraction httptypes.Attraction) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, known := d.attractions[attraction.Instance]
+	attraction.LastSeen = time.Now()
+	d.attractions[attraction.Instance] = attraction
+	return !known
+}
+
+// Known returns whether an instance of the attraction is in the directory
+func (d *AttractionDirectory) Known(attraction string, instance string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	known, ok := d.attractions[instance]
+	return ok && known.Name == attraction
+}
+
+// Remove drops an attraction from the directory, and returns whether it was in it
+func (d *AttractionDirectory) Remove(instance string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, known := d.attractions[instance]
+	delete(d.attractions, instance)
+	return known
+}
+
+// Watch drops attractions from the directory as soon as their pods stop being ready or go away,
+// instead of waiting for their heartbeats to time out
+func (d *AttractionDirectory) Watch(discovery *k8s.Discovery) {
+	events := discovery.Subscribe()
+	go func() {
+		for event := range events {
+			if event.Pod.Ready && !event.Deleted {
+				continue
+			}
+			if d.Remove(event.Pod.Instance) {
+				slog.Info("Dropped attraction whose pod went away", "attraction", event.Pod.Attraction, "instance", event.Pod.Instance, "pod", event.Pod.Pod)
+			}
+		}
+	}()
+}
+
+// Expire drops attractions that stopped sending heartbeats
+func (d *AttractionDirectory) Expire() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	expired := 0
+	for instance, attraction := range d.attractions {
+		if time.Since(attraction.LastSeen) > attractionTimeout {
+			delete(d.attractions, instance)
+			expired++
+		}
+	}
+	return expired
+}
+
+// List returns the attractions in the park ordered by instance
+func (d *AttractionDirectory) List() []httptypes.Attraction {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	attractions := make([]httptypes.Attraction, 0, len(d.attractions))
+	for _, attraction := range d.attractions {
+		attractions = append(attractions, attraction)
+	}
+	sort.Slice(attractions, func(i, j int) bool {
+		return attractions[i].Instance < attractions[j].Instance
+	})
+	return attractions
+}
+
+// Footprint returns the space taken up by attractions in acres
+func (d *AttractionDirectory) Footprint() float64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	footprint := 0.0
+	for _, attraction := range d.attractions {
+		footprint += attraction.Size
+	}
+	return footprint
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+func TestAttractionDirectoryHeartbeat(t *testing.T) {
+	directory := NewAttractionDirectory()
+
+	if !directory.Heartbeat(httptypes.Attraction{Name: "carousel", Instance: "carousel-1", Size: 0.5}) {
+		t.Errorf("Heartbeat() of a new attraction = false, want true")
+	}
+	if directory.Heartbeat(httptypes.Attraction{Name: "carousel", Instance: "carousel-1", Size: 0.5, Broken: true}) {
+		t.Errorf("Heartbeat() of a known attraction = true, want false")
+	}
+	directory.Heartbeat(httptypes.Attraction{Name: "wooden-rollercoaster", Instance: "coaster-1", Size: 2})
+
+	attractions := directory.List()
+	if len(attractions) != 2 || attractions[0].Instance != "carousel-1" || attractions[1].Instance != "coaster-1" {
+		t.Fatalf("List() = %+v, want carousel-1 and coaster-1", attractions)
+	}
+	if !attractions[0].Broken || attractions[0].LastSeen.IsZero() {
+		t.Errorf("List()[0] = %+v, want the latest heartbeat", attractions[0])
+	}
+	if got := directory.Footprint(); got != 2.5 {
+		t.Errorf("Footprint() = %v, want 2.5", got)
+	}
+}
+
+func TestAttractionDirectoryKnown(t *testing.T) {
+	directory := NewAttractionDirectory()
+	directory.Heartbeat(httptypes.Attraction{Name: "carousel", Instance: "carousel-1"})
+
+	tests := []struct {
+		attraction string
+		instance   string
+		want       bool
+	}{
+		{"carousel", "carousel-1", true},
+		{"carousel", "carousel-2", false},
+		{"restroom", "carousel-1", false},
+	}
+
+	for _, tt := range tests {
+		if got := directory.Known(tt.attraction, tt.instance); got != tt.want {
+			t.Errorf("Known(%q, %q) = %v, want %v", tt.attraction, tt.instance, got, tt.want)
+		}
+	}
+}
+
+func TestAttractionDirectoryExpire(t *testing.T) {
+	directory := NewAttractionDirectory()
+	directory.Heartbeat(httptypes.Attraction{Name: "carousel", Instance: "carousel-1"})
+	directory.Heartbeat(httptypes.Attraction{Name: "carousel", Instance: "carousel-2"})
+
+	// Age one of the attractions past the timeout
+	directory.mu.Lock()
+	stale := directory.attractions["carousel-1"]
+	stale.LastSeen = time.Now().Add(-2 * attractionTimeout)
+	directory.attractions["carousel-1"] = stale
+	directory.mu.Unlock()
+
+	if got := directory.Expire(); got != 1 {
+		t.Errorf("Expire() = %d, want 1", got)
+	}
+	if attractions := directory.List(); len(attractions) != 1 || attractions[0].Instance != "carousel-2" {
+		t.Errorf("List() after Expire() = %+v, want only carousel-2", attractions)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"kubepark/pkg/auth"
+	"kubepark/pkg/k8s"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// attractionServiceAccountPrefix is the username prefix of the ServiceAccounts allowed to request attraction credentials
+const attractionServiceAccountPrefix = "system:serviceaccount:attractions:"
+
+// Credential is the signing key issued to an attraction instance
+type Credential struct {
+	Attraction     string    `json:"attraction"`
+	Key            string    `json:"key"`
+	ServiceAccount string    `json:"service_account"`
+	Pod            string    `json:"pod"` // Pod the key was issued to
+	IssuedAt       time.Time `json:"issued_at"`
+}
+
+// Authenticator issues attraction credentials and verifies signed requests
+type Authenticator struct {
+	state     *StateManager
+	clientset kubernetes.Interface
+	discovery *k8s.Discovery
+
+	issueMu sync.Mutex // Keeps checking and replacing a credential together
+
+	started time.Time // Requests signed before are refused, as their nonces weren't seen here
+
+	mu     sync.Mutex
+	nonces map[string]bool // Nonces seen within the allowed timestamp window
+	seen   []seenNonce     // The same nonces in the order they were seen
+}
+
+// seenNonce is a nonce and when it was seen
+type seenNonce struct {
+	nonce string
+	at    time.Time
+}
+
+// NewAuthenticator creates a new authenticator. Nonces are only kept in memory, so it must be
+// created once this replica leads the park: requests signed before are refused, which keeps a
+// request sent to the previous leader from being replayed to this one.
+func NewAuthenticator(state *StateManager, clientset kubernetes.Interface, discovery *k8s.Discovery) *Authenticator {
+	return &Authenticator{
+		state:     state,
+		clientset: clientset,
+		discovery: discovery,
+		started:   time.Now().Truncate(time.Second),
+		nonces:    make(map[string]bool),
+	}
+}
+
+// Issue reviews the ServiceAccount token and issues a new signing key for the attraction instance.
+// The token must be bound to a pod labeled with that attraction and instance, and the instance
+// keeps its key while the pod it was issued to is still around.
+func (a *Authenticator) Issue(ctx context.Context, token string, attraction string, instance string) (string, error) {
+	user, err := k8s.ReviewToken(ctx, a.clientset, token)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(user.Username, attractionServiceAccountPrefix) {
+		return "", fmt.Errorf("service account %s may not request attraction credentials", user.Username)
+	}
+
+	if user.Pod == "" {
+		return "", fmt.Errorf("token of %s is not bound to a pod", user.Username)
+	}
+
+	pod, ok := a.discovery.Pod(user.Pod)
+	if !ok {
+		return "", fmt.Errorf("pod %s is not an attraction pod", user.Pod)
+	}
+
+	if pod.Attraction != attraction || pod.Instance != instance {
+		return "", fmt.Errorf("pod %s runs %s instance %s, not %s instance %s", pod.Pod, pod.Attraction, pod.Instance, attraction, instance)
+	}
+
+	a.issueMu.Lock()
+	defer a.issueMu.Unlock()
+
+	if current, ok := a.state.GetCredential(instance); ok && current.Pod != "" && current.Pod != pod.Pod {
+		if _, running := a.discovery.Pod(current.Pod); running {
+			return "", fmt.Errorf("instance %s is in use by pod %s", instance, current.Pod)
+		}
+	}
+
+	key, err := auth.NewKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	err = a.state.SetCredential(instance, Credential{
+		Attraction:     attraction,
+		Key:            key,
+		ServiceAccount: user.Username,
+		Pod:            pod.Pod,
+		IssuedAt:       time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// Staff reviews the ServiceAccount token of a member of staff, and returns the name of the pod
+// the token is bound to, which is who the member of staff is
+func (a *Authenticator) Staff(ctx context.Context, token string) (string, error) {
+	return k8s.ReviewStaffToken(ctx, a.clientset, token)
+}
+
+// Verify checks that the request is signed by a known attraction instance and isn't replayed,
+// and returns the instance and its credential
+func (a *Authenticator) Verify(r *http.Request, body []byte) (string, Credential, error) {
+	instance := r.Header.Get(auth.HeaderAttraction)
+
+	credential, ok := a.state.GetCredential(instance)
+	if !ok {
+		return "", Credential{}, fmt.Errorf("unknown attraction %q", instance)
+	}
+
+	now := time.Now()
+	if err := auth.Verify(r, credential.Key, body, now); err != nil {
+		return "", Credential{}, err
+	}
+
+	// The timestamp was checked by the signature verification
+	seconds, _ := strconv.ParseInt(r.Header.Get(auth.HeaderTimestamp), 10, 64)
+	if time.Unix(seconds, 0).Before(a.started) {
+		return "", Credential{}, fmt.Errorf("request signed before the park started")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Forget nonces whose timestamps can no longer pass verification, oldest first
+	now = time.Now()
+	expired := 0
+	for expired < len(a.seen) && now.Sub(a.seen[expired].at) > 2*auth.MaxSkew {
+		delete(a.nonces, a.seen[expired].nonce)
+		expired++
+	}
+	a.seen = a.seen[expired:]
+
+	nonce := instance + "/" + r.Header.Get(auth.HeaderNonce)
+	if a.nonces[nonce] {
+		return "", Credential{}, fmt.Errorf("replayed request")
+	}
+	a.nonces[nonce] = true
+	a.seen = append(a.seen, seenNonce{nonce: nonce, at: now})
+
+	return instance, credential, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"kubepark/pkg/auth"
+)
+
+func TestAuthenticatorVerify(t *testing.T) {
+	state, err := NewStateManager(&Config{}, &Scenario{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := auth.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := state.SetCredential("carousel-1", Credential{Attraction: "carousel", Key: key}); err != nil {
+		t.Fatal(err)
+	}
+	authenticator := NewAuthenticator(state, nil, nil)
+
+	body := []byte(`{"amount":5,"category":"ride_fee"}`)
+	sign := func(instance string, key string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://park/transaction", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := auth.Sign(req, instance, key, body); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	signed := sign("carousel-1", key)
+	tests := []struct {
+		name    string
+		req     *http.Request
+		wantErr bool
+	}{
+		{name: "signed request", req: signed},
+		{name: "replayed request", req: signed, wantErr: true},
+		{name: "next request", req: sign("carousel-1", key)},
+		{name: "unknown instance", req: sign("carousel-2", key), wantErr: true},
+		{name: "wrong key", req: sign("carousel-1", "not-the-key"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance, credential, err := authenticator.Verify(tt.req, body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if instance != "carousel-1" || credential.Attraction != "carousel" {
+				t.Errorf("Verify() = %s, %s, want carousel-1, carousel", instance, credential.Attraction)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorVerifyAfterFailover(t *testing.T) {
+	state, err := NewStateManager(&Config{}, &Scenario{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := auth.NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := state.SetCredential("carousel-1", Credential{Attraction: "carousel", Key: key}); err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"amount":5,"category":"ride_fee"}`)
+	req, err := http.NewRequest(http.MethodPost, "http://park/transaction", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.Sign(req, "carousel-1", key, body); err != nil {
+		t.Fatal(err)
+	}
+
+	// A request the previous leader saw can't be replayed to the park that took over
+	authenticator := NewAuthenticator(state, nil, nil)
+	authenticator.started = time.Now().Add(time.Minute)
+	if _, _, err := authenticator.Verify(req, body); err == nil {
+		t.Errorf("Verify() of a request signed before the park started succeeded, want an error")
+	}
+
+	// Nonces are forgotten once their timestamps can no longer pass verification
+	authenticator = NewAuthenticator(state, nil, nil)
+	authenticator.nonces["carousel-1/old"] = true
+	authenticator.seen = []seenNonce{{nonce: "carousel-1/old", at: time.Now().Add(-3 * auth.MaxSkew)}}
+	if _, _, err := authenticator.Verify(req, body); err != nil {
+		t.Fatal(err)
+	}
+	if authenticator.nonces["carousel-1/old"] || len(authenticator.seen) != 1 {
+		t.Errorf("Verify() kept %d nonces, want only the new one", len(authenticator.seen))
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+// calendarDays is the number of days the calendar endpoint looks ahead
+const calendarDays = 14
+
+// holidaySchedule is the schedule key of the opening hours on holidays
+const holidaySchedule = "holiday"
+
+// Seasons
+const (
+	SeasonWinter = "winter"
+	SeasonSpring = "spring"
+	SeasonSummer = "summer"
+	SeasonAutumn = "autumn"
+)
+
+// CalendarParams describe how demand changes over the week and the year
+type CalendarParams struct {
+	WeekendFactor   float64   `json:"weekend_factor"`   // Demand on Saturdays and Sundays relative to weekdays
+	SeasonAmplitude float64   `json:"season_amplitude"` // How far demand swings between high and low season, from 0 to 1
+	PeakDay         int       `json:"peak_day"`         // Day of the year demand peaks
+	Holidays        []Holiday `json:"holidays,omitempty"`
+}
+
+// Holiday is a yearly date with unusual demand
+type Holiday struct {
+	Name   string  `json:"name"`
+	Date   string  `json:"date"`   // Month and day, MM-DD
+	Factor float64 `json:"factor"` // Demand relative to an ordinary day
+}
+
+// defaultCalendarParams returns the calendar of the classic game modes
+func defaultCalendarParams() CalendarParams {
+	return CalendarParams{
+		WeekendFactor:   1.5,
+		SeasonAmplitude: 0.3,
+		PeakDay:         196, // Mid July
+		Holidays: []Holiday{
+			{Name: "new-years-day", Date: "01-01", Factor: 1.5},
+			{Name: "midsummer", Date: "06-21", Factor: 2},
+			{Name: "halloween", Date: "10-31", Factor: 2},
+			{Name: "christmas", Date: "12-25", Factor: 0.3},
+		},
+	}
+}
+
+// validate checks that the calendar makes sense
+func (p CalendarParams) validate() error {
+	if p.WeekendFactor < 0 {
+		return fmt.Errorf("weekend factor must not be negative")
+	}
+	if p.SeasonAmplitude < 0 || p.SeasonAmplitude > 1 {
+		return fmt.Errorf("season amplitude must be between 0 and 1")
+	}
+	if p.PeakDay < 1 || p.PeakDay > 366 {
+		return fmt.Errorf("peak day must be a day of the year")
+	}
+	for _, holiday := range p.Holidays {
+		if _, err := time.Parse("01-02", holiday.Date); err != nil {
+			return fmt.Errorf("holiday %s must have a date like 12-25", holiday.Name)
+		}
+		if holiday.Factor < 0 {
+			return fmt.Errorf("holiday %s must not have a negative factor", holiday.Name)
+		}
+	}
+	return nil
+}
+
+// Calendar knows what kind of day each simulated date is and when the park is open on it
+type Calendar struct {
+	params   CalendarParams
+	holidays map[string]Holiday // Holidays by MM-DD
+}
+
+// NewCalendar creates a calendar
+func NewCalendar(params CalendarParams) *Calendar {
+	holidays := make(map[string]Holiday, len(params.Holidays))
+	for _, holiday := range params.Holidays {
+		holidays[holiday.Date] = holiday
+	}
+
+	return &Calendar{
+		params:   params,
+		holidays: holidays,
+	}
+}
+
+// holiday returns the holiday on the date of t, if there is one
+func (c *Calendar) holiday(t time.Time) (Holiday, bool) {
+	holiday, ok := c.holidays[t.Format("01-02")]
+	return holiday, ok
+}
+
+// season returns the season of the date of t
+func season(t time.Time) string {
+	switch t.Month() {
+	case time.December, time.January, time.February:
+		return SeasonWinter
+	case time.March, time.April, time.May:
+		return SeasonSpring
+	case time.June, time.July, time.August:
+		return SeasonSummer
+	default:
+		return SeasonAutumn
+	}
+}
+
+// DemandFactor returns how much the date of t scales guest demand
+func (c *Calendar) DemandFactor(t time.Time) float64 {
+	factor := 1.0
+
+	if weekday := t.Weekday(); weekday == time.Saturday || weekday == time.Sunday {
+		factor *= c.params.WeekendFactor
+	}
+
+	// Demand follows a yearly wave that peaks on the peak day
+	phase := 2 * math.Pi * float64(t.YearDay()-c.params.PeakDay) / 365
+	factor *= 1 + c.params.SeasonAmplitude*math.Cos(phase)
+
+	if holiday, ok := c.holiday(t); ok {
+		factor *= holiday.Factor
+	}
+
+	return factor
+}
+
+// hours returns the opening hours of the day that starts on the date of t
+func (c *Calendar) hours(settings httptypes.ParkSettings, t time.Time) httptypes.Hours {
+	if _, ok := c.holiday(t); ok {
+		if hours, ok := settings.Schedule[holidaySchedule]; ok {
+			return hours
+		}
+	}
+	if hours, ok := settings.Schedule[strings.ToLower(t.Weekday().String())]; ok {
+		return hours
+	}
+	return httptypes.Hours{OpensAt: settings.OpensAt, ClosesAt: settings.ClosesAt}
+}
+
+// window returns when the park opens and closes on the day that starts on the date of t
+func (c *Calendar) window(settings httptypes.ParkSettings, t time.Time) (opens time.Time, closes time.Time) {
+	hours := c.hours(settings, t)
+
+	opens = time.Date(t.Year(), t.Month(), t.Day(), hours.OpensAt, 0, 0, 0, t.Location())
+	closes = time.Date(t.Year(), t.Month(), t.Day(), hours.ClosesAt, 0, 0, 0, t.Location())
+	if !closes.After(opens) {
+		closes = closes.AddDate(0, 0, 1)
+	}
+	return opens, closes
+}
+
+// DayOf returns the day t belongs to, which is the day before after midnight if the
+// park is still open from then
+func (c *Calendar) DayOf(settings httptypes.ParkSettings, t time.Time) time.Time {
+	before := t.AddDate(0, 0, -1)
+	if _, closes := c.window(settings, before); t.Before(closes) {
+		return before
+	}
+	return t
+}
+
+// IsClosed returns whether the park is closed at t. Hours that cross midnight keep
+// the park open into the next date.
+func (c *Calendar) IsClosed(settings httptypes.ParkSettings, t time.Time) bool {
+	if settings.Closed {
+		return true
+	}
+
+	opens, closes := c.window(settings, c.DayOf(settings, t))
+	return t.Before(opens) || !t.Before(closes)
+}
+
+// NextOpening returns the next time the park opens after t
+func (c *Calendar) NextOpening(settings httptypes.ParkSettings, t time.Time) time.Time {
+	for day := -1; ; day++ {
+		if opens, _ := c.window(settings, t.AddDate(0, 0, day)); opens.After(t) {
+			return opens
+		}
+	}
+}
+
+// NextClosing returns the next time the park closes after t
+func (c *Calendar) NextClosing(settings httptypes.ParkSettings, t time.Time) time.Time {
+	for day := -1; ; day++ {
+		if _, closes := c.window(settings, t.AddDate(0, 0, day)); closes.After(t) {
+			return closes
+		}
+	}
+}
+
+// Day describes the day that starts on the date of t
+func (c *Calendar) Day(settings httptypes.ParkSettings, weather *WeatherGenerator, t time.Time) httptypes.CalendarDay {
+	opens, closes := c.window(settings, t)
+
+	day := httptypes.CalendarDay{
+		Date:    t.Format(time.DateOnly),
+		Weekday: strings.ToLower(t.Weekday().String()),
+		Season:  season(t),
+		Opens:   opens,
+		Closes:  closes,
+		Demand:  c.DemandFactor(t),
+		Weather: weather.On(t).Condition,
+		Closed:  settings.Closed,
+	}
+	if holiday, ok := c.holiday(t); ok {
+		day.Holiday = holiday.Name
+	}
+	return day
+}
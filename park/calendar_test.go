@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+// at returns a time on a day in June 2025, which starts on a Sunday
+func at(day int, hour int, minute int) time.Time {
+	return time.Date(2025, time.June, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestCalendarOpeningHours(t *testing.T) {
+	calendar := NewCalendar(CalendarParams{
+		Holidays: []Holiday{{Name: "midsummer", Date: "06-21", Factor: 2}},
+	})
+
+	daytime := httptypes.ParkSettings{OpensAt: 9, ClosesAt: 21}
+	overnight := httptypes.ParkSettings{OpensAt: 18, ClosesAt: 2}
+	scheduled := httptypes.ParkSettings{
+		OpensAt:  9,
+		ClosesAt: 21,
+		Schedule: map[string]httptypes.Hours{
+			"saturday":      {OpensAt: 10, ClosesAt: 1},
+			holidaySchedule: {OpensAt: 8, ClosesAt: 23},
+		},
+	}
+	closed := httptypes.ParkSettings{OpensAt: 9, ClosesAt: 21, Closed: true}
+
+	tests := []struct {
+		name        string
+		settings    httptypes.ParkSettings
+		t           time.Time
+		wantClosed  bool
+		wantDay     time.Time // Date the time belongs to
+		wantOpening time.Time
+		wantClosing time.Time
+	}{
+		{
+			name: "daytime before opening", settings: daytime, t: at(2, 8, 0),
+			wantClosed: true, wantDay: at(2, 8, 0), wantOpening: at(2, 9, 0), wantClosing: at(2, 21, 0),
+		},
+		{
+			name: "daytime at opening", settings: daytime, t: at(2, 9, 0),
+			wantClosed: false, wantDay: at(2, 9, 0), wantOpening: at(3, 9, 0), wantClosing: at(2, 21, 0),
+		},
+		{
+			name: "daytime at closing", settings: daytime, t: at(2, 21, 0),
+			wantClosed: true, wantDay: at(2, 21, 0), wantOpening: at(3, 9, 0), wantClosing: at(3, 21, 0),
+		},
+		{
+			name: "overnight in the evening", settings: overnight, t: at(2, 23, 0),
+			wantClosed: false, wantDay: at(2, 23, 0), wantOpening: at(3, 18, 0), wantClosing: at(3, 2, 0),
+		},
+		{
+			name: "overnight after midnight", settings: overnight, t: at(3, 1, 0),
+			wantClosed: false, wantDay: at(2, 1, 0), wantOpening: at(3, 18, 0), wantClosing: at(3, 2, 0),
+		},
+		{
+			name: "overnight at closing", settings: overnight, t: at(3, 2, 0),
+			wantClosed: true, wantDay: at(3, 2, 0), wantOpening: at(3, 18, 0), wantClosing: at(4, 2, 0),
+		},
+		{
+			name: "overnight in the afternoon", settings: overnight, t: at(3, 12, 0),
+			wantClosed: true, wantDay: at(3, 12, 0), wantOpening: at(3, 18, 0), wantClosing: at(4, 2, 0),
+		},
+		{
+			name: "Saturday hours run past midnight", settings: scheduled, t: at(8, 0, 30),
+			wantClosed: false, wantDay: at(7, 0, 30), wantOpening: at(8, 9, 0), wantClosing: at(8, 1, 0),
+		},
+		{
+			name: "Sunday keeps the usual hours", settings: scheduled, t: at(8, 9, 30),
+			wantClosed: false, wantDay: at(8, 9, 30), wantOpening: at(9, 9, 0), wantClosing: at(8, 21, 0),
+		},
+		{
+			name: "holiday hours", settings: scheduled, t: at(21, 8, 30),
+			wantClosed: false, wantDay: at(21, 8, 30), wantOpening: at(22, 9, 0), wantClosing: at(21, 23, 0),
+		},
+		{
+			name: "closed by settings", settings: closed, t: at(2, 12, 0),
+			wantClosed: true, wantDay: at(2, 12, 0), wantOpening: at(3, 9, 0), wantClosing: at(2, 21, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calendar.IsClosed(tt.settings, tt.t); got != tt.wantClosed {
+				t.Errorf("IsClosed() = %v, want %v", got, tt.wantClosed)
+			}
+			if got := calendar.DayOf(tt.settings, tt.t); got.Format(time.DateOnly) != tt.wantDay.Format(time.DateOnly) {
+				t.Errorf("DayOf() = %s, want %s", got.Format(time.DateOnly), tt.wantDay.Format(time.DateOnly))
+			}
+			if got := calendar.NextOpening(tt.settings, tt.t); !got.Equal(tt.wantOpening) {
+				t.Errorf("NextOpening() = %s, want %s", got, tt.wantOpening)
+			}
+			if got := calendar.NextClosing(tt.settings, tt.t); !got.Equal(tt.wantClosing) {
+				t.Errorf("NextClosing() = %s, want %s", got, tt.wantClosing)
+			}
+		})
+	}
+}
+
+func TestCalendarDemandFactor(t *testing.T) {
+	calendar := NewCalendar(CalendarParams{
+		WeekendFactor:   1.5,
+		SeasonAmplitude: 0.5,
+		PeakDay:         at(4, 0, 0).YearDay(),
+		Holidays:        []Holiday{{Name: "midsummer", Date: "06-21", Factor: 2}},
+	})
+
+	peak := 1.5 // Season factor on the peak day
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want float64
+	}{
+		{"weekday at the peak of the season", at(4, 12, 0), peak},
+		{"weekend near the peak", at(7, 12, 0), 1.5 * (1 + 0.5*math.Cos(2*math.Pi*3/365))},
+		{"holiday on a Saturday", at(21, 12, 0), 2 * 1.5 * (1 + 0.5*math.Cos(2*math.Pi*17/365))},
+		{"low season half a year later", time.Date(2025, time.December, 3, 12, 0, 0, 0, time.UTC), 1 + 0.5*math.Cos(2*math.Pi*182/365)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calendar.DemandFactor(tt.t); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("DemandFactor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+// maxClockSpeed is the highest speed multiplier the clock accepts
+const maxClockSpeed = 100
+
+// Clock drives the simulated park time
+type Clock interface {
+	// Now returns the current simulated time
+	Now() time.Time
+	// Advance moves simulated time forward for the given amount of real time
+	// and returns how much simulated time passed
+	Advance(real time.Duration) (time.Duration, error)
+	// Pause freezes simulated time
+	Pause() error
+	// Resume unfreezes simulated time
+	Resume() error
+	// SetSpeed sets the speed multiplier applied on top of the base time scale
+	SetSpeed(speed float64) error
+	// FastForward jumps simulated time forward to t
+	FastForward(t time.Time) error
+	// Status returns a snapshot of the clock
+	Status() httptypes.Clock
+}
+
+// SimClock is a Clock whose time, pause flag and speed are persisted in the park state
+type SimClock struct {
+	state     *StateManager
+	timeScale float64 // Simulated seconds per real second at speed 1
+}
+
+// NewSimClock creates a new clock backed by the park state
+func NewSimClock(state *StateManager, timeScale float64) *SimClock {
+	return &SimClock{
+		state:     state,
+		timeScale: timeScale,
+	}
+}
+
+// Now returns the current simulated time
+func (c *SimClock) Now() time.Time {
+	return c.state.GetTime()
+}
+
+// Advance moves simulated time forward unless the clock is paused. Pausing or fast-forwarding
+// at the same time either happens before or after, never in between.
+func (c *SimClock) Advance(real time.Duration) (time.Duration, error) {
+	return c.state.AdvanceTime(func(clock ClockState) time.Duration {
+		return time.Duration(float64(real) * c.timeScale * clock.Speed)
+	})
+}
+
+// Pause freezes simulated time
+func (c *SimClock) Pause() error {
+	return c.state.SetClockPaused(true)
+}
+
+// Resume unfreezes simulated time
+func (c *SimClock) Resume() error {
+	return c.state.SetClockPaused(false)
+}
+
+// SetSpeed sets the speed multiplier of the clock
+func (c *SimClock) SetSpeed(speed float64) error {
+	if speed <= 0 || speed > maxClockSpeed {
+		return fmt.Errorf("speed must be greater than 0 and at most %v", maxClockSpeed)
+	}
+	return c.state.SetClockSpeed(speed)
+}
+
+// FastForward jumps simulated time forward to t
+func (c *SimClock) FastForward(t time.Time) error {
+	return c.state.FastForwardTime(t)
+}
+
+// Status returns a snapshot of the clock
+func (c *SimClock) Status() httptypes.Clock {
+	clock := c.state.GetClock()
+	return httptypes.Clock{
+		Time:   c.Now(),
+		Paused: clock.Paused,
+		Speed:  clock.Speed,
+	}
+}
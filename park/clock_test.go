@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestClock(t *testing.T, timeScale float64) *SimClock {
+	t.Helper()
+
+	state, err := NewStateManager(&Config{}, &Scenario{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSimClock(state, timeScale)
+}
+
+func TestSimClockAdvance(t *testing.T) {
+	clock := newTestClock(t, 60)
+	start := clock.Now()
+
+	elapsed, err := clock.Advance(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed != time.Minute || !clock.Now().Equal(start.Add(time.Minute)) {
+		t.Errorf("Advance(1s) = %v, now %v, want a minute from %v", elapsed, clock.Now(), start)
+	}
+
+	// The speed multiplies the time scale
+	if err := clock.SetSpeed(2); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed, _ := clock.Advance(time.Second); elapsed != 2*time.Minute {
+		t.Errorf("Advance(1s) at speed 2 = %v, want 2m", elapsed)
+	}
+
+	// Nothing passes while the clock is paused
+	if err := clock.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	paused := clock.Now()
+	if elapsed, _ := clock.Advance(time.Second); elapsed != 0 || !clock.Now().Equal(paused) {
+		t.Errorf("Advance(1s) while paused = %v, want 0", elapsed)
+	}
+	if !clock.Status().Paused {
+		t.Errorf("Status().Paused = false after Pause()")
+	}
+
+	if err := clock.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed, _ := clock.Advance(time.Second); elapsed != 2*time.Minute {
+		t.Errorf("Advance(1s) after Resume() = %v, want 2m", elapsed)
+	}
+}
+
+func TestSimClockSetSpeed(t *testing.T) {
+	clock := newTestClock(t, 60)
+
+	for _, speed := range []float64{0, -1, maxClockSpeed + 1} {
+		if err := clock.SetSpeed(speed); err == nil {
+			t.Errorf("SetSpeed(%v) succeeded, want an error", speed)
+		}
+	}
+	if err := clock.SetSpeed(maxClockSpeed); err != nil {
+		t.Errorf("SetSpeed(%v) error = %v", maxClockSpeed, err)
+	}
+	if got := clock.Status().Speed; got != maxClockSpeed {
+		t.Errorf("Status().Speed = %v, want %v", got, maxClockSpeed)
+	}
+}
+
+func TestSimClockFastForward(t *testing.T) {
+	clock := newTestClock(t, 60)
+	start := clock.Now()
+
+	if err := clock.FastForward(start.Add(-time.Hour)); err == nil {
+		t.Errorf("FastForward() into the past succeeded, want an error")
+	}
+	if !clock.Now().Equal(start) {
+		t.Errorf("Now() = %v after a failed FastForward(), want %v", clock.Now(), start)
+	}
+
+	target := start.Add(36 * time.Hour)
+	if err := clock.FastForward(target); err != nil {
+		t.Fatal(err)
+	}
+	if !clock.Now().Equal(target) {
+		t.Errorf("Now() = %v, want %v", clock.Now(), target)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"kubepark/pkg/logger"
 	"os"
 )
 
@@ -16,22 +17,41 @@ type Config struct {
 	OpensAt       int
 	ClosesAt      int
 	LogLevel      string
+	LogFormat     string
 	GrafanaURL    string
 	GrafanaAPIKey string
+	TimeScale     float64
+	ArrivalModel  string
+	ScenarioPath  string
+	SettingsPath  string
+	GuestMode     string
+	CrowdURL      string
+
+	LeaseNamespace string // Namespace of the leader election lease
+	Identity       string // Name of this replica in leader election
 }
 
 func RegisterFlags(config *Config) {
 	flag.StringVar(&config.Image, "image", "", "The image to use for guests, should be same as the park image")
 	flag.StringVar(&config.SelfURL, "self-url", "", "URL where this attraction can be reached")
-	flag.StringVar(&config.Mode, "mode", "easy", "Game mode (easy, medium, hard)")
+	flag.StringVar(&config.Mode, "mode", "easy", "Game mode (easy, medium, hard), the scenario file builds on top of it")
+	flag.StringVar(&config.ScenarioPath, "scenario", "", "Path to a YAML or JSON scenario file, e.g. mounted from a ConfigMap")
 	flag.StringVar(&config.VolumePath, "volume", "", "Path to volume for persistent storage")
+	flag.StringVar(&config.SettingsPath, "settings", "", "Path to a YAML or JSON settings file that is applied live and takes precedence over flags")
 	flag.BoolVar(&config.Closed, "closed", false, "Whether the park is closed")
 	flag.Float64Var(&config.EntranceFee, "entrance-fee", 10, "Entrance fee for the park")
 	flag.IntVar(&config.OpensAt, "opens-at", 8, "Hour at which the park opens")
 	flag.IntVar(&config.ClosesAt, "closes-at", 20, "Hour at which the park closes")
 	flag.StringVar(&config.LogLevel, "log-level", "info", "Log level (debug, info, warn, error)")
+	flag.StringVar(&config.LogFormat, "log-format", logger.FormatText, "Log format (text, json), also used by guests")
 	flag.StringVar(&config.GrafanaURL, "grafana-url", "http://kubepark-grafana:3000", "Grafana server URL for Live streaming")
 	flag.StringVar(&config.GrafanaAPIKey, "grafana-api-key", "", "Grafana API key for Live streaming")
+	flag.Float64Var(&config.TimeScale, "time-scale", 100, "Simulated seconds that pass per real second at speed 1")
+	flag.StringVar(&config.ArrivalModel, "arrival-model", "", "How guests arrive at the park (flat, demand), overrides the scenario")
+	flag.StringVar(&config.GuestMode, "guest-mode", GuestModeJobs, "How guests are spawned (jobs, crowd)")
+	flag.StringVar(&config.CrowdURL, "crowd-url", "http://guest-crowd.guests.svc.cluster.local.", "URL of the guest crowd, used with --guest-mode crowd")
+	flag.StringVar(&config.LeaseNamespace, "lease-namespace", "park", "Namespace of the lease park replicas elect a leader with")
+	flag.StringVar(&config.Identity, "identity", os.Getenv("HOSTNAME"), "Name of this park replica in leader election")
 	flag.Parse()
 
 	// Override with environment variables if set
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/tracing"
+)
+
+const (
+	// crowdRefreshInterval is how often the park asks the crowd how it's doing
+	crowdRefreshInterval = 5 * time.Second
+
+	// crowdQueueSize is how many guests may wait to be sent to the crowd
+	crowdQueueSize = 1000
+)
+
+// errCrowdFull is returned when the guest crowd simulates as many guests as it can. The crowd
+// being full says nothing about the park, so these guests aren't turned away by the park.
+var errCrowdFull = errors.New("guest crowd is full")
+
+// GuestCrowd spawns guests into a guest crowd, a single deployment simulating many guests at
+// once, so large parks don't need a pod per guest. Guests are sent to the crowd and its status
+// is refreshed in the background, so a slow or missing crowd doesn't hold up the simulation loop.
+type GuestCrowd struct {
+	url       string
+	maxGuests int // Most guests in the park at once, unlimited if 0
+	client    *http.Client
+	queue     chan GuestVisit // Guests waiting to be sent to the crowd
+
+	mu      sync.Mutex
+	status  httptypes.CrowdStatus // Last known status of the crowd
+	pending int                   // Guests spawned that the crowd hasn't taken in yet
+}
+
+// NewGuestCrowd creates a spawner for the guest crowd at the given URL
+func NewGuestCrowd(url string, maxGuests int) *GuestCrowd {
+	return &GuestCrowd{
+		url:       url,
+		maxGuests: maxGuests,
+		client:    tracing.NewClient(2 * time.Second),
+		queue:     make(chan GuestVisit, crowdQueueSize),
+	}
+}
+
+// Start sends spawned guests to the crowd and keeps its status up to date until the context
+// is done. The crowd may be deployed after the park, so it's only a warning when it can't be
+// found yet.
+func (c *GuestCrowd) Start(ctx context.Context) error {
+	go c.send(ctx)
+	go func() {
+		if err := c.refresh(ctx); err != nil {
+			slog.Warn("Guest crowd not reachable yet", "url", c.url, "error", err)
+		}
+
+		ticker := time.NewTicker(crowdRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := c.refresh(ctx); err != nil {
+				slog.Debug("Failed to refresh guest crowd status", "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Spawn queues a guest to be sent into the park by the crowd, unless the park or the queue is full
+func (c *GuestCrowd) Spawn(ctx context.Context, visit GuestVisit) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxGuests > 0 && c.status.Active+c.pending >= c.maxGuests {
+		return errParkFull
+	}
+
+	select {
+	case c.queue <- visit:
+		c.pending++
+		return nil
+	default:
+		return fmt.Errorf("too many guests waiting for the guest crowd")
+	}
+}
+
+// send sends queued guests to the crowd until the context is done
+func (c *GuestCrowd) send(ctx context.Context) {
+	for {
+		var visit GuestVisit
+		select {
+		case <-ctx.Done():
+			return
+		case visit = <-c.queue:
+		}
+
+		err := c.join(ctx, visit)
+
+		c.mu.Lock()
+		c.pending = max(0, c.pending-1)
+		c.mu.Unlock()
+
+		if err != nil {
+			metrics.GuestsNotSpawned.Inc()
+			slog.Warn("Failed to send guest to the guest crowd", "error", err)
+		}
+	}
+}
+
+// join asks the crowd to send a guest into the park
+func (c *GuestCrowd) join(ctx context.Context, visit GuestVisit) error {
+	data, err := json.Marshal(httptypes.CrowdGuest{Money: visit.Money})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/guests", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach guest crowd: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return errCrowdFull
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("guest crowd refused guest with status: %d", resp.StatusCode)
+	}
+
+	var status httptypes.CrowdStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("failed to decode guest crowd status: %w", err)
+	}
+
+	c.mu.Lock()
+	c.status = status
+	c.mu.Unlock()
+	return nil
+}
+
+// SendHome drops the guests still waiting for the crowd and asks every guest of the crowd
+// to leave the park
+func (c *GuestCrowd) SendHome(ctx context.Context) (int, error) {
+	c.mu.Lock()
+	for dropped := true; dropped; {
+		select {
+		case <-c.queue:
+			c.pending = max(0, c.pending-1)
+		default:
+			dropped = false
+		}
+	}
+	active := c.status.Active
+	c.mu.Unlock()
+
+	// The park sends guests home every second while it's closed, only bother the crowd when
+	// there's someone to send home
+	if active == 0 {
+		return 0, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/guests/leave", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach guest crowd: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("guest crowd refused to leave with status: %d", resp.StatusCode)
+	}
+
+	var dismissal httptypes.CrowdDismissal
+	if err := json.NewDecoder(resp.Body).Decode(&dismissal); err != nil {
+		return 0, fmt.Errorf("failed to decode guest crowd dismissal: %w", err)
+	}
+
+	return dismissal.SentHome, nil
+}
+
+// Phases counts the guests of the crowd visiting the park and done visiting it, as of the last
+// refresh. Guests waiting to be sent to the crowd are pending.
+func (c *GuestCrowd) Phases() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	phases := newPhases()
+	phases[PhasePending] = c.pending
+	phases[PhaseRunning] = c.status.Active
+	phases[PhaseSucceeded] = c.status.Finished
+	phases[PhaseFailed] = c.status.Failed
+	return phases
+}
+
+// refresh asks the crowd how it's doing
+func (c *GuestCrowd) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/guests", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach guest crowd: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("guest crowd status request failed with status: %d", resp.StatusCode)
+	}
+
+	var status httptypes.CrowdStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("failed to decode guest crowd status: %w", err)
+	}
+
+	c.mu.Lock()
+	c.status = status
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+func TestGuestCrowdJoin(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantErr    bool
+		wantFull   bool
+		wantActive int
+	}{
+		{name: "joined", status: http.StatusOK, wantActive: 3},
+		{name: "crowd full", status: http.StatusServiceUnavailable, wantErr: true, wantFull: true},
+		{name: "crowd failing", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/guests" {
+					t.Errorf("crowd got %s %s, want POST /guests", r.Method, r.URL.Path)
+				}
+				if tt.status != http.StatusOK {
+					http.Error(w, "no", tt.status)
+					return
+				}
+				json.NewEncoder(w).Encode(httptypes.CrowdStatus{Size: 10, Active: 3})
+			}))
+			defer server.Close()
+
+			crowd := NewGuestCrowd(server.URL, 0)
+			err := crowd.join(context.Background(), GuestVisit{Money: 100})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("join() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// A full or failing crowd is never mistaken for a full park
+			if errors.Is(err, errParkFull) {
+				t.Errorf("join() error = %v, the park isn't full", err)
+			}
+			if errors.Is(err, errCrowdFull) != tt.wantFull {
+				t.Errorf("join() error = %v, want errCrowdFull %v", err, tt.wantFull)
+			}
+			if got := crowd.Phases()[PhaseRunning]; got != tt.wantActive {
+				t.Errorf("running guests = %d, want %d", got, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestGuestCrowdJoinUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	crowd := NewGuestCrowd(server.URL, 0)
+	err := crowd.join(context.Background(), GuestVisit{Money: 100})
+	if err == nil || errors.Is(err, errParkFull) || errors.Is(err, errCrowdFull) {
+		t.Errorf("join() error = %v, want a connection error", err)
+	}
+}
+
+func TestGuestCrowdSpawnCap(t *testing.T) {
+	crowd := NewGuestCrowd("http://crowd.invalid", 3)
+	crowd.status = httptypes.CrowdStatus{Active: 1}
+
+	visit := GuestVisit{Scenario: "easy", Day: 1, Arrival: time.Now(), Money: 100}
+	for i := range 2 {
+		if err := crowd.Spawn(context.Background(), visit); err != nil {
+			t.Fatalf("Spawn() #%d error = %v", i+1, err)
+		}
+	}
+
+	// Guests waiting to be sent count towards the cap along with those in the park
+	if err := crowd.Spawn(context.Background(), visit); !errors.Is(err, errParkFull) {
+		t.Errorf("Spawn() over the cap error = %v, want errParkFull", err)
+	}
+	if got := crowd.Phases()[PhasePending]; got != 2 {
+		t.Errorf("pending guests = %d, want 2", got)
+	}
+}
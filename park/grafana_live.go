@@ -2,20 +2,51 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// GrafanaLiveClient handles streaming data to Grafana Live
+const (
+	// liveBufferSize is how many points are kept while Grafana can't be reached, older points are dropped
+	liveBufferSize = 1000
+
+	// liveBatchSize is the most points sent to Grafana in one push
+	liveBatchSize = 100
+
+	// liveFlushInterval is how often buffered points are sent when a batch isn't full
+	liveFlushInterval = time.Second
+
+	// liveMinBackoff and liveMaxBackoff bound how long to wait before trying Grafana again after a failed push
+	liveMinBackoff = time.Second
+	liveMaxBackoff = time.Minute
+)
+
+// escapeMeasurement and escapeTag escape the characters with meaning in the InfluxDB line protocol
+var (
+	escapeMeasurement = strings.NewReplacer(",", `\,`, " ", `\ `)
+	escapeTag         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// GrafanaLiveClient handles streaming data to Grafana Live. Points are published without
+// waiting on Grafana, and sent in batches in the background, so a slow or missing Grafana
+// never holds up the simulation.
 type GrafanaLiveClient struct {
 	baseURL  string
 	apiKey   string
 	streamID string
 	client   *http.Client
 	enabled  bool
+
+	points chan string   // Points in line protocol waiting to be batched
+	done   chan struct{} // Closed once the last points were sent
 }
 
 // NewGrafanaLiveClient creates a new Grafana Live client
@@ -36,28 +67,118 @@ func NewGrafanaLiveClient(grafanaURL, apiKey string) *GrafanaLiveClient {
 			Timeout: 5 * time.Second,
 		},
 		enabled: enabled,
+		points:  make(chan string, liveBufferSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start sends published points to Grafana Live until the context is done
+func (g *GrafanaLiveClient) Start(ctx context.Context) {
+	if !g.enabled {
+		close(g.done)
+		return
 	}
+
+	go g.run(ctx)
 }
 
-// PushMetric sends a metric to Grafana Live
-func (g *GrafanaLiveClient) PushMetric(metricName string, value float64, tags map[string]string) error {
+// Wait waits for the last points to be sent after the context is done
+func (g *GrafanaLiveClient) Wait() {
+	<-g.done
+}
+
+// Publish queues a metric to be sent to Grafana Live. It never blocks, the point is dropped
+// when the buffer is full or the value can't be written in the line protocol.
+func (g *GrafanaLiveClient) Publish(metricName string, value float64, tags map[string]string) {
 	if !g.enabled {
-		return nil // Silently skip if not enabled
+		return // Silently skip if not enabled
+	}
+
+	point, ok := linePoint(metricName, value, tags, time.Now())
+	if !ok {
+		metrics.LivePoints.WithLabelValues("invalid").Inc()
+		return
 	}
 
-	// Build tags string
-	tagsStr := "source=kubepark"
-	for k, v := range tags {
-		tagsStr += fmt.Sprintf(",%s=%s", k, v)
+	select {
+	case g.points <- point:
+	default:
+		metrics.LivePoints.WithLabelValues("dropped").Inc()
 	}
+}
 
-	// Format data in InfluxDB line protocol
-	now := time.Now().UnixNano()
-	data := fmt.Sprintf("%s,%s value=%.2f %d\n", metricName, tagsStr, value, now)
+// run batches published points and pushes them, backing off while Grafana fails
+func (g *GrafanaLiveClient) run(ctx context.Context) {
+	defer close(g.done)
+
+	ticker := time.NewTicker(liveFlushInterval)
+	defer ticker.Stop()
+
+	var batch []string
+	var backoff time.Duration
+	var retryAt time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Give Grafana one last chance at the points that are left
+			for len(g.points) > 0 {
+				batch = append(batch, <-g.points)
+			}
+			for len(batch) > 0 {
+				sending := batch[:min(len(batch), liveBatchSize)]
+				if err := g.push(sending); err != nil {
+					slog.Warn("Failed to push last points to Grafana Live", "error", err, "dropped", len(batch))
+					metrics.LivePoints.WithLabelValues("dropped").Add(float64(len(batch)))
+					break
+				}
+				batch = batch[len(sending):]
+			}
+			return
+		case point := <-g.points:
+			batch = append(batch, point)
+			if dropped := len(batch) - liveBufferSize; dropped > 0 {
+				batch = slices.Delete(batch, 0, dropped)
+				metrics.LivePoints.WithLabelValues("dropped").Add(float64(dropped))
+			}
+			if len(batch) < liveBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		metrics.LiveBuffered.Set(float64(len(batch) + len(g.points)))
+		if len(batch) == 0 || time.Now().Before(retryAt) {
+			continue
+		}
+
+		sending := batch[:min(len(batch), liveBatchSize)]
+		if err := g.push(sending); err != nil {
+			backoff = min(max(2*backoff, liveMinBackoff), liveMaxBackoff)
+			retryAt = time.Now().Add(backoff)
+			metrics.LiveBackoff.Set(backoff.Seconds())
+			slog.Warn("Failed to push to Grafana Live, backing off", "error", err, "backoff", backoff, "buffered", len(batch))
+			continue
+		}
+
+		backoff = 0
+		retryAt = time.Time{}
+		metrics.LiveBackoff.Set(0)
+		batch = batch[len(sending):]
+		metrics.LiveBuffered.Set(float64(len(batch) + len(g.points)))
+	}
+}
+
+// push sends a batch of points to Grafana Live in a single request
+func (g *GrafanaLiveClient) push(points []string) error {
+	start := time.Now()
+	defer func() {
+		metrics.LivePushDuration.Observe(time.Since(start).Seconds())
+	}()
 
 	// Create HTTP request to Grafana Live Push API
 	url := fmt.Sprintf("%s/api/live/push/%s", g.baseURL, g.streamID)
-	req, err := http.NewRequest("POST", url, bytes.NewBufferString(data))
+	req, err := http.NewRequest("POST", url, bytes.NewBufferString(strings.Join(points, "")))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -69,21 +190,56 @@ func (g *GrafanaLiveClient) PushMetric(metricName string, value float64, tags ma
 	// Send request
 	resp, err := g.client.Do(req)
 	if err != nil {
+		metrics.LivePushes.WithLabelValues("failed").Inc()
 		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		metrics.LivePushes.WithLabelValues("failed").Inc()
 		// Read response body for more details
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("grafana live push failed with status: %d, response: %s", resp.StatusCode, string(body))
 	}
 
-	slog.Debug("Successfully pushed metric to Grafana Live",
-		"metric", metricName,
-		"value", value,
-		"tags", tags,
-		"status", resp.StatusCode)
+	metrics.LivePushes.WithLabelValues("sent").Inc()
+	metrics.LivePoints.WithLabelValues("sent").Add(float64(len(points)))
+	slog.Debug("Successfully pushed points to Grafana Live", "points", len(points), "status", resp.StatusCode)
 
 	return nil
 }
+
+// linePoint formats a metric as a point in the InfluxDB line protocol, with its tags sorted and
+// its timestamp in nanoseconds. It returns false for NaN and infinite values, which the line
+// protocol can't express and which would get the whole batch rejected.
+func linePoint(metricName string, value float64, tags map[string]string, at time.Time) (string, bool) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString(escapeMeasurement.Replace(metricName))
+	b.WriteString(",source=kubepark")
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if k == "" || tags[k] == "" {
+			continue // Empty tag keys and values aren't allowed in the line protocol
+		}
+		b.WriteString(",")
+		b.WriteString(escapeTag.Replace(k))
+		b.WriteString("=")
+		b.WriteString(escapeTag.Replace(tags[k]))
+	}
+
+	b.WriteString(" value=")
+	b.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	b.WriteString(" ")
+	b.WriteString(strconv.FormatInt(at.UnixNano(), 10))
+	b.WriteString("\n")
+	return b.String(), true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLinePoint(t *testing.T) {
+	at := time.Date(2025, 6, 1, 12, 0, 0, 123456789, time.UTC)
+
+	tests := []struct {
+		name   string
+		metric string
+		value  float64
+		tags   map[string]string
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "no tags",
+			metric: "park_money",
+			value:  1234.5,
+			want:   "park_money,source=kubepark value=1234.5 1748779200123456789\n",
+			wantOK: true,
+		},
+		{
+			name:   "whole numbers without exponent",
+			metric: "park_time",
+			value:  1748779200000,
+			want:   "park_time,source=kubepark value=1748779200000 1748779200123456789\n",
+			wantOK: true,
+		},
+		{
+			name:   "negative value",
+			metric: "park_money",
+			value:  -20,
+			want:   "park_money,source=kubepark value=-20 1748779200123456789\n",
+			wantOK: true,
+		},
+		{
+			name:   "tags sorted by key",
+			metric: "park_guests",
+			value:  3,
+			tags:   map[string]string{"weather": "rain", "day": "2", "attraction": "carousel"},
+			want:   "park_guests,source=kubepark,attraction=carousel,day=2,weather=rain value=3 1748779200123456789\n",
+			wantOK: true,
+		},
+		{
+			name:   "measurement escaped",
+			metric: "park money,total",
+			value:  1,
+			want:   `park\ money\,total,source=kubepark value=1 1748779200123456789` + "\n",
+			wantOK: true,
+		},
+		{
+			name:   "tag keys and values escaped",
+			metric: "park_guests",
+			value:  1,
+			tags:   map[string]string{"ride name": "tea cups, fast=yes"},
+			want:   `park_guests,source=kubepark,ride\ name=tea\ cups\,\ fast\=yes value=1 1748779200123456789` + "\n",
+			wantOK: true,
+		},
+		{
+			name:   "empty tags left out",
+			metric: "park_guests",
+			value:  1,
+			tags:   map[string]string{"attraction": "", "": "carousel"},
+			want:   "park_guests,source=kubepark value=1 1748779200123456789\n",
+			wantOK: true,
+		},
+		{name: "NaN skipped", metric: "park_money", value: math.NaN()},
+		{name: "positive infinity skipped", metric: "park_money", value: math.Inf(1)},
+		{name: "negative infinity skipped", metric: "park_money", value: math.Inf(-1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := linePoint(tt.metric, tt.value, tt.tags, at)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("linePoint() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"kubepark/pkg/constants"
+
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	// maxVisitDuration is how long a guest may stay before it's assumed to have left without saying so
+	maxVisitDuration = 30 * time.Minute
+
+	// guestIDLength is the number of random characters in a guest ID
+	guestIDLength = 16
+)
+
+// fits returns whether the number of guests fit in the park next to the attractions' footprint
+func fits(guests int, footprint float64, totalSpace float64) bool {
+	return float64(guests)*constants.GuestSize+footprint <= totalSpace
+}
+
+// guestVisit is what the registry knows about a guest inside the park
+type guestVisit struct {
+	entered  time.Time
+	reported bool // Whether the guest sent its visit report
+}
+
+// GuestRegistry tracks the guests currently inside the park
+type GuestRegistry struct {
+	mu     sync.Mutex
+	guests map[string]guestVisit // Visits by guest ID
+}
+
+// NewGuestRegistry creates a new guest registry
+func NewGuestRegistry() *GuestRegistry {
+	return &GuestRegistry{
+		guests: make(map[string]guestVisit),
+	}
+}
+
+// Admit lets a guest in if admit approves of the number of guests that would be inside,
+// and returns the new guest's ID
+func (g *GuestRegistry) Admit(admit func(guests int) bool) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !admit(len(g.guests) + 1) {
+		return "", false
+	}
+
+	// Draw again in the unlikely case the ID is taken
+	id := utilrand.String(guestIDLength)
+	for {
+		if _, taken := g.guests[id]; !taken {
+			break
+		}
+		id = utilrand.String(guestIDLength)
+	}
+
+	g.guests[id] = guestVisit{entered: time.Now()}
+	return id, true
+}
+
+// Report marks that a guest inside the park sent its visit report, and returns false
+// if the guest isn't inside or already reported
+func (g *GuestRegistry) Report(id string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	visit, ok := g.guests[id]
+	if !ok || visit.reported {
+		return false
+	}
+	visit.reported = true
+	g.guests[id] = visit
+	return true
+}
+
+// Leave removes a guest from the park and returns whether it was inside
+func (g *GuestRegistry) Leave(id string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, ok := g.guests[id]
+	delete(g.guests, id)
+	return ok
+}
+
+// Expire removes guests that have been inside for longer than a visit can take
+func (g *GuestRegistry) Expire() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	expired := 0
+	for id, visit := range g.guests {
+		if time.Since(visit.entered) > maxVisitDuration {
+			delete(g.guests, id)
+			expired++
+		}
+	}
+	return expired
+}
+
+// Clear removes all guests from the park
+func (g *GuestRegistry) Clear() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.guests = make(map[string]guestVisit)
+}
+
+// Count returns the number of guests inside the park
+func (g *GuestRegistry) Count() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.guests)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGuestRegistryAdmit(t *testing.T) {
+	tests := []struct {
+		name       string
+		totalSpace float64
+		footprint  float64
+		arrivals   int
+		wantInside int
+	}{
+		{"room for everyone", 10, 0, 5, 5},
+		{"exactly full", 1, 0, 10, 10},
+		{"turned away once full", 1, 0, 15, 10},
+		{"attractions take up space", 1, 0.5, 10, 5},
+		{"no room at all", 1, 1, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guests := NewGuestRegistry()
+			admit := func(count int) bool {
+				return fits(count, tt.footprint, tt.totalSpace)
+			}
+
+			for range tt.arrivals {
+				guests.Admit(admit)
+			}
+
+			if got := guests.Count(); got != tt.wantInside {
+				t.Errorf("Count() = %d, want %d", got, tt.wantInside)
+			}
+		})
+	}
+}
+
+func TestGuestRegistryLeaveAndReport(t *testing.T) {
+	guests := NewGuestRegistry()
+	id, ok := guests.Admit(func(int) bool { return true })
+	if !ok {
+		t.Fatal("Admit() refused the guest")
+	}
+
+	steps := []struct {
+		name string
+		do   func() bool
+		want bool
+	}{
+		{"unknown guest reports", func() bool { return guests.Report("unknown") }, false},
+		{"guest reports", func() bool { return guests.Report(id) }, true},
+		{"guest reports again", func() bool { return guests.Report(id) }, false},
+		{"guest leaves", func() bool { return guests.Leave(id) }, true},
+		{"guest leaves again", func() bool { return guests.Leave(id) }, false},
+	}
+
+	for _, step := range steps {
+		if got := step.do(); got != step.want {
+			t.Errorf("%s = %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	if got := guests.Count(); got != 0 {
+		t.Errorf("Count() = %d, want 0", got)
+	}
+}
+
+func TestGuestRegistryAdmitIDs(t *testing.T) {
+	guests := NewGuestRegistry()
+
+	ids := make(map[string]bool)
+	for range 1000 {
+		id, ok := guests.Admit(func(int) bool { return true })
+		if !ok || len(id) != guestIDLength || ids[id] {
+			t.Fatalf("Admit() = %q, %v, want a new ID", id, ok)
+		}
+		ids[id] = true
+	}
+}
@@ -2,60 +2,627 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
+	"kubepark/pkg/auth"
 	"kubepark/pkg/httptypes"
+	"kubepark/pkg/k8s"
 )
 
 // handleStatus handles requests to check if this is a park service
-func handleStatus(config *Config, state *StateManager) http.HandlerFunc {
+func handleStatus(state *StateManager, guests *GuestRegistry, weather *WeatherGenerator, calendar *Calendar) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
+		now := state.GetTime()
+		settings := state.GetSettings()
+		reputation := state.GetReputation()
+		attractionRatings := make(map[string]float64)
+		for instance, rating := range reputation.Attractions {
+			attractionRatings[instance] = rating.Score
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(httptypes.Park{
-			IsClosed:   isClosed(config, state.GetTime()),
-			TotalSpace: state.GetTotalSpace(),
-			Money:      state.GetMoney(),
+			IsClosed:          calendar.IsClosed(settings, now),
+			TotalSpace:        state.GetTotalSpace(),
+			Money:             state.GetMoney(),
+			Guests:            guests.Count(),
+			Time:              now,
+			Bankrupt:          state.IsBankrupt(),
+			Rating:            reputation.Park.Score,
+			Weather:           weather.On(calendar.DayOf(settings, now)).Condition,
+			AttractionRatings: attractionRatings,
 		})
 	}
 }
 
-// handleTransaction handles payment requests from attractions
-func handleTransaction(state *StateManager) http.HandlerFunc {
+// handleClock handles requests to inspect and control the simulation clock
+func handleClock(state *StateManager, clock Clock, calendar *Calendar) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var req httptypes.ClockRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			var err error
+			switch req.Action {
+			case httptypes.ClockPause:
+				err = clock.Pause()
+			case httptypes.ClockResume:
+				err = clock.Resume()
+			case httptypes.ClockSpeed:
+				err = clock.SetSpeed(req.Speed)
+			case httptypes.ClockFastForward:
+				err = clock.FastForward(calendar.NextOpening(state.GetSettings(), clock.Now()))
+			default:
+				http.Error(w, fmt.Sprintf("Unknown clock action %q", req.Action), http.StatusBadRequest)
+				return
+			}
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			slog.Info("Updated clock", "action", req.Action, "speed", req.Speed)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(clock.Status())
+	}
+}
+
+// handleCredentials handles requests from attractions for transaction signing credentials
+func handleCredentials(authenticator *Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			http.Error(w, "Missing service account token", http.StatusUnauthorized)
+			return
+		}
+
+		var req httptypes.CredentialsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Attraction == "" || req.Instance == "" {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		key, err := authenticator.Issue(r.Context(), token, req.Attraction, req.Instance)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Refused attraction credentials", "attraction", req.Attraction, "instance", req.Instance, "error", err)
+			http.Error(w, "Failed to issue credentials", http.StatusForbidden)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Issued attraction credentials", "attraction", req.Attraction, "instance", req.Instance)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(httptypes.CredentialsResponse{Key: key})
+	}
+}
+
+// handleTransaction handles signed payment requests from attractions
+func handleTransaction(state *StateManager, scenario *Scenario, authenticator *Authenticator) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		instance, credential, err := authenticator.Verify(r, body)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Rejected transaction", "instance", r.Header.Get(auth.HeaderAttraction), "error", err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
 		var req httptypes.TransactionRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if err := json.Unmarshal(body, &req); err != nil {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
 
-		// Add the payment to the park's cash
-		state.AddMoney(req.Amount)
+		if err := checkAttractionTransaction(req.Category, req.Amount); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.Category == httptypes.CategoryBuild && state.IsBankrupt() {
+			http.Error(w, "The park is bankrupt", http.StatusForbidden)
+			return
+		}
+
+		if req.Category == httptypes.CategoryBuild && !scenario.allows(credential.Attraction) {
+			http.Error(w, fmt.Sprintf("%s may not be built in this scenario", credential.Attraction), http.StatusForbidden)
+			return
+		}
+
+		// Record the payment in the ledger
+		if err := recordTransaction(state, req.Category, credential.Attraction, instance, req.Amount); err != nil {
+			http.Error(w, "Failed to record transaction", http.StatusInternalServerError)
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
+// handleLedger handles requests to query the park ledger
+func handleLedger(state *StateManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		filter, err := parseLedgerFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ledger := httptypes.Ledger{Entries: state.GetLedger(filter)}
+		for _, entry := range ledger.Entries {
+			ledger.Net += entry.Net()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ledger)
+	}
+}
+
 // handleEnter handles guest entry requests
-func handleEnter(state *StateManager) http.HandlerFunc {
+func handleEnter(state *StateManager, guests *GuestRegistry, attractions *AttractionDirectory) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
+		if state.IsBankrupt() {
+			http.Error(w, "The park is bankrupt", http.StatusServiceUnavailable)
+			return
+		}
+
+		// Only let the guest in if everyone still fits next to the attractions
+		footprint := attractions.Footprint()
+		totalSpace := state.GetTotalSpace()
+		id, ok := guests.Admit(func(count int) bool {
+			return fits(count, footprint, totalSpace)
+		})
+		if !ok {
+			slog.InfoContext(r.Context(), "Refused guest, park is full", "guests", guests.Count(), "footprint", footprint, "total_space", totalSpace)
+			http.Error(w, "Park is full", http.StatusServiceUnavailable)
+			return
+		}
+
 		// Process entrance fee
-		state.AddMoney(state.GetEntranceFee())
-		slog.Info("Accepted guest")
+		if err := recordTransaction(state, httptypes.CategoryEntranceFee, "", "", state.GetEntranceFee()); err != nil {
+			guests.Leave(id)
+			http.Error(w, "Failed to process entrance fee", http.StatusInternalServerError)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Accepted guest", "guest_id", id, "guests", guests.Count())
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(httptypes.EnterResponse{GuestID: id})
+	}
+}
+
+// handleVisitReport handles the reports guests send when they leave
+func handleVisitReport(state *StateManager, guests *GuestRegistry, attractions *AttractionDirectory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var report httptypes.VisitReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// Only guests inside the park get a say, and only once
+		if !guests.Report(report.GuestID) {
+			http.Error(w, "Guest is not in the park or already reported", http.StatusForbidden)
+			return
+		}
+
+		// Rides on attractions the park doesn't know about don't count
+		rides := report.Rides[:0]
+		for _, ride := range report.Rides {
+			if attractions.Known(ride.Attraction, ride.Instance) {
+				rides = append(rides, ride)
+			}
+		}
+		report.Rides = rides
+
+		reputation, err := state.AddVisitReport(report)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to record visit report", "error", err)
+			http.Error(w, "Failed to record visit report", http.StatusInternalServerError)
+			return
+		}
+
+		metrics.VisitReports.Inc()
+		setReputationMetrics(reputation)
+
+		slog.InfoContext(r.Context(), "Received visit report",
+			"guest_id", report.GuestID,
+			"rides", len(report.Rides),
+			"failures", len(report.Failures),
+			"satisfaction", report.Satisfaction,
+			"rating", reputation.Park.Score)
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// handleReports handles requests for the reports of closed days in JSON or CSV
+func handleReports(state *StateManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		reports := state.GetReports()
+
+		switch r.URL.Query().Get("format") {
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(reports)
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="kubepark-reports.csv"`)
+			if err := writeReportsCSV(w, reports); err != nil {
+				slog.Error("Failed to write reports", "error", err)
+			}
+		default:
+			http.Error(w, "Unknown format, use json or csv", http.StatusBadRequest)
+		}
+	}
+}
+
+// handleLoans handles requests to list and take out loans
+func handleLoans(state *StateManager, terms LoanTerms) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var req httptypes.LoanRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			loan, entry, err := state.TakeLoan(req.Amount, terms)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			observeEntry(entry)
+			slog.Info("Took out loan", "id", loan.ID, "amount", loan.Principal, "rate", loan.Rate)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		loans := state.GetLoans(terms)
+		setLoanMetrics(loans)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(loans)
+	}
+}
+
+// handleLand handles requests to inspect and buy the park's land
+func handleLand(state *StateManager, terms LandTerms) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var req httptypes.LandRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			entry, err := state.BuyLand(req.Acres, terms)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			observeEntry(entry)
+			slog.Info("Bought land", "acres", req.Acres, "cost", -entry.Amount)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		land := state.GetLand(terms)
+		setLandMetrics(land)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(land)
+	}
+}
+
+// handleRepayLoan handles requests to pay back a loan
+func handleRepayLoan(state *StateManager, terms LoanTerms) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req httptypes.RepayRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		entry, err := state.RepayLoan(req.LoanID, req.Amount)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		observeEntry(entry)
+		slog.Info("Repaid loan", "id", req.LoanID, "amount", entry.Amount)
+
+		loans := state.GetLoans(terms)
+		setLoanMetrics(loans)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(loans)
+	}
+}
+
+// handleScenario handles requests for the progress through the scenario
+func handleScenario(state *StateManager, scenario *Scenario, attractions *AttractionDirectory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(scenario.status(state.GetScenarioState(), state.GetDay(), objectiveValues(state, attractions)))
+	}
+}
+
+// handleExit handles guests leaving the park
+func handleExit(guests *GuestRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req httptypes.ExitRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if !guests.Leave(req.GuestID) {
+			http.Error(w, "Guest is not in the park", http.StatusNotFound)
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Guest left", "guest_id", req.GuestID, "guests", guests.Count())
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// handleStaff handles heartbeats of staff on duty and requests for who is on duty. Staff are
+// known by the pod their ServiceAccount token is bound to, so only staff pods go on the payroll.
+func handleStaff(staff *StaffRegistry, params StaffParams, authenticator *Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" {
+				http.Error(w, "Missing service account token", http.StatusUnauthorized)
+				return
+			}
+
+			var req httptypes.StaffHeartbeat
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			if _, ok := params.wage(req.Role); !ok {
+				http.Error(w, fmt.Sprintf("Unknown staff role %q", req.Role), http.StatusBadRequest)
+				return
+			}
+
+			name, err := authenticator.Staff(r.Context(), token)
+			if err != nil {
+				slog.WarnContext(r.Context(), "Refused staff heartbeat", "role", req.Role, "error", err)
+				http.Error(w, "Not allowed on duty", http.StatusForbidden)
+				return
+			}
+
+			staff.Heartbeat(name, req.Role)
+			slog.Debug("Staff on duty", "name", name, "role", req.Role)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(staff.List(params))
+	}
+}
+
+// handleAttractions handles signed heartbeats from attractions and requests for the attraction directory
+func handleAttractions(directory *AttractionDirectory, discovery *k8s.Discovery, authenticator *Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			instance, credential, err := authenticator.Verify(r, body)
+			if err != nil {
+				slog.WarnContext(r.Context(), "Rejected attraction heartbeat", "instance", r.Header.Get(auth.HeaderAttraction), "error", err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			var attraction httptypes.Attraction
+			if err := json.Unmarshal(body, &attraction); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			// The credentials say who the attraction is, and it can be reached at its pod
+			// or where the heartbeat came from unless it says otherwise
+			attraction.Name = credential.Attraction
+			attraction.Instance = instance
+			if pod, ok := discovery.Get(instance); ok && attraction.URL == "" {
+				attraction.URL = pod.URL()
+			}
+			if attraction.URL == "" {
+				host, _, err := net.SplitHostPort(r.RemoteAddr)
+				if err != nil {
+					http.Error(w, "Attraction URL must be set", http.StatusBadRequest)
+					return
+				}
+				attraction.URL = "http://" + host
+			}
+
+			if directory.Heartbeat(attraction) {
+				slog.InfoContext(r.Context(), "Registered attraction", "attraction", attraction.Name, "instance", attraction.Instance, "url", attraction.URL)
+			}
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(directory.List())
+	}
+}
+
+// recordTransaction records a transaction in the ledger and updates the money flow metrics
+func recordTransaction(state *StateManager, category httptypes.TransactionCategory, attraction string, instance string, amount float64) error {
+	entry, err := state.Record(category, attraction, instance, amount)
+	if err != nil {
+		slog.Error("Failed to record transaction", "category", category, "instance", instance, "amount", amount, "error", err)
+		return err
+	}
+
+	observeEntry(entry)
+	return nil
+}
+
+// observeEntry updates the money flow metrics with a recorded ledger entry
+func observeEntry(entry httptypes.LedgerEntry) {
+	direction := "in"
+	if entry.Credit == httptypes.AccountCash {
+		direction = "out"
+	}
+	metrics.MoneyFlow.WithLabelValues(string(entry.Category), direction).Add(entry.Amount)
+
+	slog.Debug("Recorded transaction",
+		"id", entry.ID,
+		"category", entry.Category,
+		"instance", entry.Instance,
+		"amount", entry.Net(),
+		"balance", entry.Balance)
+}
+
+// handleSettings handles requests for the settings the park runs with and their audit log
+func handleSettings(state *StateManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state.GetSettingsStatus())
+	}
+}
+
+// handleWeather handles requests for the weather forecast, from the same day /park-status
+// reports the weather of, which is the day before after midnight while the park is still open
+func handleWeather(state *StateManager, weather *WeatherGenerator, calendar *Calendar) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(weather.Forecast(calendar.DayOf(state.GetSettings(), state.GetTime())))
+	}
+}
+
+// handleCalendar handles requests for the coming days, their opening hours and demand
+func handleCalendar(state *StateManager, weather *WeatherGenerator, calendar *Calendar) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Start from the day of the given time instead of today, e.g. for attractions catching up on upkeep
+		from := state.GetTime()
+		if query := r.URL.Query().Get("from"); query != "" {
+			var err error
+			if from, err = time.Parse(time.RFC3339, query); err != nil {
+				http.Error(w, fmt.Sprintf("invalid from: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		settings := state.GetSettings()
+		first := calendar.DayOf(settings, from)
+		days := make([]httptypes.CalendarDay, 0, calendarDays)
+		for day := range calendarDays {
+			days = append(days, calendar.Day(settings, weather, first.AddDate(0, 0, day)))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(days)
+	}
+}
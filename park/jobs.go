@@ -3,91 +3,293 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
-// GuestJobManager manages Kubernetes jobs for park guests
+const (
+	// guestNamespace is where guest jobs run
+	guestNamespace = "guests"
+
+	// guestSelector picks out the jobs the park created for guests
+	guestSelector = "app.kubernetes.io/component=guest"
+
+	// guestJobTTL is how long finished guest jobs are kept around to look at before Kubernetes deletes them
+	guestJobTTL = 5 * time.Minute
+
+	// guestJobResync is how often the informer replays every cached job
+	guestJobResync = 5 * time.Minute
+
+	// guestNameSuffixLength is how many random characters follow guest- in a guest job's name
+	guestNameSuffixLength = 8
+)
+
+// GuestJobManager spawns every guest as a Kubernetes job of its own. It watches the guest jobs
+// through an informer, so counting guests doesn't list the jobs on every tick.
 type GuestJobManager struct {
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
+	image     string          // Image of the guest jobs, the same as the park's
+	parkURL   string          // Where guests find the park
+	logFormat string          // Format guests log in, the same as the park's
+	env       []corev1.EnvVar // Tracing settings guests share with the park
+	factory   informers.SharedInformerFactory
+	jobs      batchlisters.JobLister
+	synced    cache.InformerSynced
+
+	mu        sync.Mutex
+	maxGuests int                 // Most unfinished guest jobs at once, unlimited if 0
+	created   map[string]struct{} // Jobs being created or created that the informer hasn't seen yet
 }
 
 // NewGuestJobManager creates a new guest job manager
-func NewGuestJobManager() (*GuestJobManager, error) {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get in-cluster config: %w", err)
+func NewGuestJobManager(clientset kubernetes.Interface, image string, parkURL string, logFormat string, maxGuests int) (*GuestJobManager, error) {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, guestJobResync,
+		informers.WithNamespace(guestNamespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = guestSelector
+		}),
+	)
+
+	m := &GuestJobManager{
+		clientset: clientset,
+		image:     image,
+		parkURL:   parkURL,
+		logFormat: logFormat,
+		env:       tracingEnv(),
+		factory:   factory,
+		maxGuests: maxGuests,
+		created:   make(map[string]struct{}),
 	}
 
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	informer := factory.Batch().V1().Jobs().Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if job, ok := obj.(*batchv1.Job); ok {
+				m.mu.Lock()
+				delete(m.created, job.Name)
+				m.mu.Unlock()
+			}
+		},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to watch guest jobs: %w", err)
 	}
 
-	return &GuestJobManager{
-		clientset: clientset,
-	}, nil
+	m.jobs = factory.Batch().V1().Jobs().Lister()
+	m.synced = informer.HasSynced
+	return m, nil
 }
 
-// CreateGuestJob creates a new guest job
-func (m *GuestJobManager) CreateGuestJob(ctx context.Context, image string, parkURL string) error {
+// Start watches the guest jobs until the context is done, and waits for the cache to fill
+func (m *GuestJobManager) Start(ctx context.Context) error {
+	m.factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), m.synced) {
+		return fmt.Errorf("timed out waiting for guest jobs to sync")
+	}
+	return nil
+}
+
+// Spawn creates a new guest job, unless the park is full
+func (m *GuestJobManager) Spawn(ctx context.Context, visit GuestVisit) error {
+	// Reserve the guest's place before creating its job, so a burst of arrivals can't go over
+	// the cap without the lock being held while the API server creates the job. The informer
+	// releases the reservation once it sees the job.
+	name := "guest-" + utilrand.String(guestNameSuffixLength)
+
+	m.mu.Lock()
+	if m.maxGuests > 0 && m.unfinished()+len(m.created) >= m.maxGuests {
+		m.mu.Unlock()
+		return errParkFull
+	}
+	m.created[name] = struct{}{}
+	m.mu.Unlock()
+
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			GenerateName: "guest-",
+			Name: name,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":      "kubepark",
+				"app.kubernetes.io/component": "guest",
+				"kubepark/scenario":           visit.Scenario,
+				"kubepark/day":                strconv.Itoa(visit.Day),
+			},
+			Annotations: map[string]string{
+				"kubepark/arrival": visit.Arrival.Format(time.RFC3339),
+				"kubepark/money":   strconv.FormatFloat(visit.Money, 'f', 2, 64),
+			},
 		},
 		Spec: batchv1.JobSpec{
 			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app.kubernetes.io/name":      "kubepark",
+						"app.kubernetes.io/component": "guest",
+					},
+				},
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{
 						{
 							Name:    "guest",
-							Image:   image,
+							Image:   m.image,
 							Command: []string{"/opt/kubepark/internal/guest"},
 							Args: []string{
-								"--park-url", parkURL,
+								"--park-url", m.parkURL,
+								"--money", strconv.FormatFloat(visit.Money, 'f', 2, 64),
+								"--log-format", m.logFormat,
+							},
+							Env: m.env,
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("10m"),
+									corev1.ResourceMemory: resource.MustParse("16Mi"),
+								},
+								Limits: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("100m"),
+									corev1.ResourceMemory: resource.MustParse("64Mi"),
+								},
 							},
 						},
 					},
 					RestartPolicy: corev1.RestartPolicyNever,
 				},
 			},
-			BackoffLimit: int32Ptr(4),
+			// A guest that fails is not sent in again, since it would pay the entrance fee twice
+			BackoffLimit:            int32Ptr(0),
+			TTLSecondsAfterFinished: int32Ptr(int32(guestJobTTL.Seconds())),
 		},
 	}
 
-	_, err := m.clientset.BatchV1().Jobs("guests").Create(ctx, job, metav1.CreateOptions{})
-	if err != nil {
+	if _, err := m.clientset.BatchV1().Jobs(guestNamespace).Create(ctx, job, metav1.CreateOptions{}); err != nil {
+		m.mu.Lock()
+		delete(m.created, name)
+		m.mu.Unlock()
 		return fmt.Errorf("failed to create guest job: %w", err)
 	}
 
 	return nil
 }
 
-// CleanupJobs removes all jobs
-func (m *GuestJobManager) CleanupJobs(ctx context.Context) (int, error) {
-	jobs, err := m.clientset.BatchV1().Jobs("guests").List(ctx, metav1.ListOptions{})
+// SendHome deletes the job of every guest that hasn't finished its visit. Finished guest jobs
+// are left for their TTL to clean up. The jobs are deleted without holding the lock, so spawning
+// and counting guests don't wait on the API server.
+func (m *GuestJobManager) SendHome(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	clear(m.created)
+	m.mu.Unlock()
+
+	jobs, err := m.jobs.Jobs(guestNamespace).List(labels.Everything())
 	if err != nil {
 		return 0, fmt.Errorf("failed to list jobs: %w", err)
 	}
 
 	backgroundDeletion := metav1.DeletePropagationBackground
-	for _, job := range jobs.Items {
-		err := m.clientset.BatchV1().Jobs("guests").Delete(ctx, job.Name, metav1.DeleteOptions{
+	removed := 0
+	for _, job := range jobs {
+		if finished(job) {
+			continue
+		}
+
+		err := m.clientset.BatchV1().Jobs(guestNamespace).Delete(ctx, job.Name, metav1.DeleteOptions{
 			PropagationPolicy: &backgroundDeletion,
 		})
+		if apierrors.IsNotFound(err) {
+			continue // Gone since it was listed
+		}
 		if err != nil {
-			return len(jobs.Items), fmt.Errorf("failed to delete job %s: %w", job.Name, err)
+			return removed, fmt.Errorf("failed to delete job %s: %w", job.Name, err)
 		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// Phases counts the guest jobs in every phase
+func (m *GuestJobManager) Phases() map[string]int {
+	phases := newPhases()
+
+	jobs, err := m.jobs.Jobs(guestNamespace).List(labels.Everything())
+	if err != nil {
+		return phases
 	}
+	for _, job := range jobs {
+		phases[jobPhase(job)]++
+	}
+
+	m.mu.Lock()
+	phases[PhasePending] += len(m.created)
+	m.mu.Unlock()
 
-	return len(jobs.Items), nil
+	return phases
+}
+
+// unfinished counts the guest jobs still in the park. The caller holds the lock.
+func (m *GuestJobManager) unfinished() int {
+	jobs, err := m.jobs.Jobs(guestNamespace).List(labels.Everything())
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, job := range jobs {
+		if !finished(job) {
+			count++
+		}
+	}
+	return count
+}
+
+// jobPhase tells which phase a guest job is in
+func jobPhase(job *batchv1.Job) string {
+	switch {
+	case job.Status.Succeeded > 0:
+		return PhaseSucceeded
+	case job.Status.Failed > 0:
+		return PhaseFailed
+	case job.Status.Ready != nil && *job.Status.Ready > 0:
+		return PhaseRunning
+	default:
+		return PhasePending
+	}
+}
+
+// finished tells whether a guest job is done, whether it succeeded or not
+func finished(job *batchv1.Job) bool {
+	phase := jobPhase(job)
+	return phase == PhaseSucceeded || phase == PhaseFailed
 }
 
 // int32Ptr returns a pointer to the given int32 value
 func int32Ptr(i int32) *int32 {
 	return &i
 }
+
+// tracingEnv passes the park's OpenTelemetry settings on to guests, so their spans end up
+// in the same place. Guests name their own service.
+func tracingEnv() []corev1.EnvVar {
+	var env []corev1.EnvVar
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, "OTEL_") || name == "OTEL_SERVICE_NAME" {
+			continue
+		}
+		env = append(env, corev1.EnvVar{Name: name, Value: value})
+	}
+	slices.SortFunc(env, func(a, b corev1.EnvVar) int { return strings.Compare(a.Name, b.Name) })
+	return env
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGuestJobManagerSpawnCap(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	m, err := NewGuestJobManager(clientset, "kubepark", "http://park", "json", 2)
+	if err != nil {
+		t.Fatalf("NewGuestJobManager() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	visit := GuestVisit{Scenario: "easy", Day: 1, Arrival: time.Now(), Money: 100}
+	for i := range 2 {
+		if err := m.Spawn(ctx, visit); err != nil {
+			t.Fatalf("Spawn() #%d error = %v", i+1, err)
+		}
+	}
+	if err := m.Spawn(ctx, visit); !errors.Is(err, errParkFull) {
+		t.Fatalf("Spawn() over the cap error = %v, want errParkFull", err)
+	}
+
+	// A guest that finished its visit makes room for the next
+	jobs, err := clientset.BatchV1().Jobs(guestNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil || len(jobs.Items) != 2 {
+		t.Fatalf("List() = %d jobs, error %v, want 2 jobs", len(jobs.Items), err)
+	}
+	job := jobs.Items[0]
+	job.Status.Succeeded = 1
+	if _, err := clientset.BatchV1().Jobs(guestNamespace).UpdateStatus(ctx, &job, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("UpdateStatus() error = %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := m.Spawn(ctx, visit)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errParkFull) || time.Now().After(deadline) {
+			t.Fatalf("Spawn() after a guest finished error = %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGuestJobManagerSpawnFailureReleasesPlace(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	failing := true
+	clientset.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if failing {
+			return true, nil, errors.New("api server unavailable")
+		}
+		return false, nil, nil
+	})
+
+	m, err := NewGuestJobManager(clientset, "kubepark", "http://park", "json", 1)
+	if err != nil {
+		t.Fatalf("NewGuestJobManager() error = %v", err)
+	}
+
+	ctx := context.Background()
+	visit := GuestVisit{Scenario: "easy", Day: 1, Arrival: time.Now(), Money: 100}
+	if err := m.Spawn(ctx, visit); err == nil || errors.Is(err, errParkFull) {
+		t.Fatalf("Spawn() error = %v, want the create error", err)
+	}
+
+	// The place reserved for the guest that couldn't be created is free again
+	failing = false
+	if err := m.Spawn(ctx, visit); err != nil {
+		t.Fatalf("Spawn() after a failed create error = %v", err)
+	}
+
+	jobs, err := clientset.BatchV1().Jobs(guestNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil || len(jobs.Items) != 1 {
+		t.Fatalf("List() = %d jobs, error %v, want 1 job", len(jobs.Items), err)
+	}
+	if spec := jobs.Items[0].Spec; spec.BackoffLimit == nil || *spec.BackoffLimit != 0 {
+		t.Errorf("BackoffLimit = %v, want 0 so guests never pay twice", spec.BackoffLimit)
+	}
+}
+
+func TestGuestJobManagerSpawnDoesNotBlockWhileCreating(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	creating := make(chan struct{})
+	release := make(chan struct{})
+	clientset.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		close(creating)
+		<-release
+		return false, nil, nil
+	})
+
+	m, err := NewGuestJobManager(clientset, "kubepark", "http://park", "json", 0)
+	if err != nil {
+		t.Fatalf("NewGuestJobManager() error = %v", err)
+	}
+
+	spawned := make(chan error)
+	go func() {
+		spawned <- m.Spawn(context.Background(), GuestVisit{Scenario: "easy", Day: 1, Arrival: time.Now(), Money: 100})
+	}()
+	<-creating
+
+	// The guest being created already counts, and asking doesn't wait for the API server
+	counted := make(chan map[string]int)
+	go func() { counted <- m.Phases() }()
+	select {
+	case phases := <-counted:
+		if phases[PhasePending] != 1 {
+			t.Errorf("Phases() = %v while creating, want 1 pending", phases)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Phases() blocked while a guest job was being created")
+	}
+
+	close(release)
+	if err := <-spawned; err != nil {
+		t.Fatalf("Spawn() error = %v", err)
+	}
+}
+
+func TestGuestJobManagerSendHomeDoesNotBlockWhileDeleting(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	deleting := make(chan struct{}, 1)
+	release := make(chan struct{})
+	clientset.PrependReactor("delete", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		select {
+		case deleting <- struct{}{}:
+		default:
+		}
+		<-release
+		return false, nil, nil
+	})
+
+	m, err := NewGuestJobManager(clientset, "kubepark", "http://park", "json", 0)
+	if err != nil {
+		t.Fatalf("NewGuestJobManager() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	visit := GuestVisit{Scenario: "easy", Day: 1, Arrival: time.Now(), Money: 100}
+	for range 3 {
+		if err := m.Spawn(ctx, visit); err != nil {
+			t.Fatalf("Spawn() error = %v", err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for m.unfinished() != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("informer didn't see the guest jobs")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	removed := make(chan int)
+	go func() {
+		n, err := m.SendHome(ctx)
+		if err != nil {
+			t.Errorf("SendHome() error = %v", err)
+		}
+		removed <- n
+	}()
+	<-deleting
+
+	// Counting guests doesn't wait for the jobs being deleted
+	counted := make(chan map[string]int)
+	go func() { counted <- m.Phases() }()
+	select {
+	case <-counted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Phases() blocked while guests were being sent home")
+	}
+
+	close(release)
+	if n := <-removed; n != 3 {
+		t.Errorf("SendHome() = %d, want 3", n)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+
+	"kubepark/pkg/httptypes"
+)
+
+// LandTerms are the conditions the park can buy more land under
+type LandTerms struct {
+	PricePerAcre  float64 `json:"price_per_acre"` // Price of an acre before the park owns any land
+	PriceIncrease float64 `json:"price_increase"` // How much the price per acre rises with every acre the park owns
+	MaxSpace      float64 `json:"max_space"`      // Most land the park may own in acres, none can be bought if 0
+}
+
+// validate checks that the land terms make sense for a park starting with the given space
+func (t LandTerms) validate(totalSpace float64) error {
+	if t.PricePerAcre < 0 || t.PriceIncrease < 0 {
+		return fmt.Errorf("land prices must not be negative")
+	}
+	if t.MaxSpace != 0 && t.MaxSpace < totalSpace {
+		return fmt.Errorf("max space must not be less than the total space")
+	}
+	return nil
+}
+
+// price returns the price per acre of a park that owns the given space
+func (t LandTerms) price(space float64) float64 {
+	return t.PricePerAcre + t.PriceIncrease*space
+}
+
+// cost returns what buying acres costs a park that owns the given space.
+// The price rises with every acre bought, so buying in one go costs the same as buying bit by bit.
+func (t LandTerms) cost(space float64, acres float64) float64 {
+	return acres * t.price(space+acres/2)
+}
+
+// buyLand buys acres of land next to the park. It must only be called while updating the state.
+func (state *ParkState) buyLand(acres float64, terms LandTerms) (httptypes.LedgerEntry, error) {
+	if state.Bankrupt {
+		return httptypes.LedgerEntry{}, fmt.Errorf("the park is bankrupt")
+	}
+	if acres <= 0 {
+		return httptypes.LedgerEntry{}, fmt.Errorf("acres must be positive")
+	}
+	if state.TotalSpace+acres > terms.MaxSpace {
+		return httptypes.LedgerEntry{}, fmt.Errorf("the park may own at most %g acres", terms.MaxSpace)
+	}
+
+	cost := terms.cost(state.TotalSpace, acres)
+	if cost > state.Money {
+		return httptypes.LedgerEntry{}, fmt.Errorf("not enough money to buy %g acres for $%.2f", acres, cost)
+	}
+
+	state.TotalSpace += acres
+	return state.record(httptypes.CategoryLand, "", "", -cost), nil
+}
+
+// land returns the land the park owns and what more costs
+func (state *ParkState) land(terms LandTerms) httptypes.Land {
+	return httptypes.Land{
+		TotalSpace:   state.TotalSpace,
+		MaxSpace:     terms.MaxSpace,
+		Available:    max(0, terms.MaxSpace-state.TotalSpace),
+		PricePerAcre: terms.price(state.TotalSpace),
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLandCost(t *testing.T) {
+	terms := LandTerms{PricePerAcre: 100, PriceIncrease: 2, MaxSpace: 1000}
+
+	tests := []struct {
+		name      string
+		space     float64
+		acres     float64
+		wantPrice float64 // Price per acre before buying
+		wantCost  float64
+	}{
+		{name: "no land yet", space: 0, acres: 1, wantPrice: 100, wantCost: 101},
+		{name: "price rises with size", space: 50, acres: 1, wantPrice: 200, wantCost: 201},
+		{name: "many acres at once", space: 50, acres: 10, wantPrice: 200, wantCost: 2100},
+		{name: "nothing", space: 50, acres: 0, wantPrice: 200, wantCost: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := terms.price(tt.space); got != tt.wantPrice {
+				t.Errorf("price() = %v, want %v", got, tt.wantPrice)
+			}
+			if got := terms.cost(tt.space, tt.acres); math.Abs(got-tt.wantCost) > 1e-9 {
+				t.Errorf("cost() = %v, want %v", got, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestLandCostInSteps(t *testing.T) {
+	terms := LandTerms{PricePerAcre: 300, PriceIncrease: 10, MaxSpace: 500}
+
+	tests := []struct {
+		name  string
+		space float64
+		acres float64
+		steps int
+	}{
+		{"two halves", 100, 10, 2},
+		{"acre by acre", 100, 10, 10},
+		{"small park", 0, 3, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step := tt.acres / float64(tt.steps)
+			inSteps := 0.0
+			for i := range tt.steps {
+				inSteps += terms.cost(tt.space+float64(i)*step, step)
+			}
+
+			if once := terms.cost(tt.space, tt.acres); math.Abs(once-inSteps) > 1e-6 {
+				t.Errorf("cost() in one go = %v, in %d steps = %v", once, tt.steps, inSteps)
+			}
+		})
+	}
+}
+
+func TestBuyLand(t *testing.T) {
+	terms := LandTerms{PricePerAcre: 100, PriceIncrease: 0, MaxSpace: 20}
+
+	tests := []struct {
+		name      string
+		state     ParkState
+		acres     float64
+		wantErr   bool
+		wantSpace float64
+		wantMoney float64
+	}{
+		{name: "affordable", state: ParkState{Money: 1000, TotalSpace: 10}, acres: 5, wantSpace: 15, wantMoney: 500},
+		{name: "up to the max", state: ParkState{Money: 1000, TotalSpace: 10}, acres: 10, wantSpace: 20, wantMoney: 0},
+		{name: "beyond the max", state: ParkState{Money: 5000, TotalSpace: 10}, acres: 11, wantErr: true, wantSpace: 10, wantMoney: 5000},
+		{name: "too expensive", state: ParkState{Money: 400, TotalSpace: 10}, acres: 5, wantErr: true, wantSpace: 10, wantMoney: 400},
+		{name: "no acres", state: ParkState{Money: 1000, TotalSpace: 10}, acres: 0, wantErr: true, wantSpace: 10, wantMoney: 1000},
+		{name: "bankrupt", state: ParkState{Money: 1000, TotalSpace: 10, Bankrupt: true}, acres: 1, wantErr: true, wantSpace: 10, wantMoney: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+
+			_, err := state.buyLand(tt.acres, terms)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buyLand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if state.TotalSpace != tt.wantSpace || state.Money != tt.wantMoney {
+				t.Errorf("buyLand() left %v acres and $%v, want %v acres and $%v", state.TotalSpace, state.Money, tt.wantSpace, tt.wantMoney)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Lease the park replicas elect a leader with
+const (
+	leaseName     = "kubepark-park"
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+	releaseWait   = 5 * time.Second
+)
+
+// leadership is the lease on the park this replica was elected with
+type leadership struct {
+	identity string
+	cancel   context.CancelFunc
+	stopped  chan struct{}                // Closed once the elector stopped
+	state    atomic.Pointer[StateManager] // State to save if the lease is lost
+}
+
+// Guard saves the state before the process exits if the lease is ever lost, so changes
+// that were only saved lazily aren't lost with it
+func (l *leadership) Guard(state *StateManager) {
+	l.state.Store(state)
+}
+
+// Release hands the lease over to a standby once the leader is done with the state
+func (l *leadership) Release() {
+	l.cancel()
+	select {
+	case <-l.stopped:
+		slog.Info("Released park leadership", "identity", l.identity)
+	case <-time.After(releaseWait):
+		slog.Warn("Timed out releasing park leadership", "identity", l.identity)
+	}
+}
+
+// lost saves the guarded state and exits, so the new leader is the only one running the park.
+// The lease is lost once it couldn't be renewed for the renew deadline, which leaves the rest
+// of the lease duration to save the state before a standby can take over.
+func (l *leadership) lost() {
+	slog.Error("Lost park leadership, stopping so the new leader is the only one running the park", "identity", l.identity)
+	if state := l.state.Load(); state != nil {
+		if err := state.Flush(); err != nil {
+			slog.Error("Failed to save state after losing park leadership", "error", err)
+		}
+	}
+	os.Exit(1)
+}
+
+// campaign blocks until this replica is elected leader of the park or the context is done.
+// The lease keeps being renewed afterwards, and the process exits if it is ever lost, so only
+// the leader writes the park state. A standby replica reports itself healthy on the metrics
+// port while it waits.
+func campaign(ctx context.Context, clientset kubernetes.Interface, namespace string, identity string) (*leadership, error) {
+	// The lease outlives the context, so the leader can save its state before letting go
+	leaseCtx, cancelLease := context.WithCancel(context.Background())
+	elected := make(chan struct{})
+	lease := &leadership{
+		identity: identity,
+		cancel:   cancelLease,
+		stopped:  make(chan struct{}),
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Name:      leaseName,
+				Namespace: namespace,
+			},
+			Client: clientset.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: identity,
+			},
+		},
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				close(elected)
+			},
+			OnStoppedLeading: func() {
+				if leaseCtx.Err() != nil {
+					return
+				}
+				lease.lost()
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					slog.Info("Standing by for park leader", "leader", leader)
+				}
+			},
+		},
+	})
+	if err != nil {
+		cancelLease()
+		return nil, fmt.Errorf("failed to create leader elector: %v", err)
+	}
+
+	// Stay healthy while standing by, the park's servers only start once elected
+	standbyMux := http.NewServeMux()
+	standbyMux.HandleFunc("/healthz", handleHealthz)
+	standby := &http.Server{
+		Addr:    ":9000",
+		Handler: standbyMux,
+	}
+	go func() {
+		if err := standby.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Standby server failed", "error", err)
+		}
+	}()
+	defer standby.Close()
+
+	go func() {
+		defer close(lease.stopped)
+		elector.Run(leaseCtx)
+	}()
+
+	select {
+	case <-elected:
+		slog.Info("Elected park leader", "identity", identity)
+		return lease, nil
+	case <-ctx.Done():
+		lease.Release()
+		return nil, ctx.Err()
+	}
+}
+
+// handleHealthz reports that the park process is alive, whether it leads or stands by
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
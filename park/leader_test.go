@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCampaign(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	lease, err := campaign(context.Background(), clientset, "kubepark", "park-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := clientset.CoordinationV1().Leases("kubepark").Get(context.Background(), leaseName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held.Spec.HolderIdentity == nil || *held.Spec.HolderIdentity != "park-0" {
+		t.Errorf("lease holder = %v, want park-0", held.Spec.HolderIdentity)
+	}
+
+	// The guarded state is the one saved if the lease is lost
+	state, err := NewStateManager(&Config{}, &Scenario{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lease.Guard(state)
+	if lease.state.Load() != state {
+		t.Errorf("Guard() didn't keep the state to save")
+	}
+}
+
+func TestCampaignRelease(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	lease, err := campaign(context.Background(), clientset, "kubepark", "park-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A standby keeps waiting while the leader holds the lease
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := campaign(ctx, clientset, "kubepark", "park-1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("campaign() while the lease is held = %v, want the deadline exceeded", err)
+	}
+
+	// Once released, the standby takes over
+	lease.Release()
+	ctx, cancel = context.WithTimeout(context.Background(), 2*leaseDuration)
+	defer cancel()
+	standby, err := campaign(ctx, clientset, "kubepark", "park-1")
+	if err != nil {
+		t.Fatalf("campaign() after the lease was released = %v, want elected", err)
+	}
+	standby.Release()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+// maxLedgerEntries is the number of most recent ledger entries kept in the park state,
+// more while the current day's entries don't fit
+const maxLedgerEntries = 5000
+
+// attractionCategories are the transaction categories attractions may submit,
+// with the sign their amounts must have: 1 for money in, -1 for money out
+var attractionCategories = map[httptypes.TransactionCategory]float64{
+	httptypes.CategoryRideFee: 1,
+	httptypes.CategoryBuild:   -1,
+	httptypes.CategoryRepair:  -1,
+	httptypes.CategoryUpkeep:  -1,
+	httptypes.CategoryRefund:  -1,
+}
+
+// checkAttractionTransaction returns why an attraction may not submit an amount in a category, if it may not
+func checkAttractionTransaction(category httptypes.TransactionCategory, amount float64) error {
+	sign, ok := attractionCategories[category]
+	if !ok {
+		return fmt.Errorf("invalid transaction category %q", category)
+	}
+	if amount*sign < 0 {
+		return fmt.Errorf("invalid amount %.2f for %s", amount, category)
+	}
+	return nil
+}
+
+// LedgerFilter selects ledger entries
+type LedgerFilter struct {
+	Category   httptypes.TransactionCategory
+	Attraction string
+	Instance   string
+	AfterID    int // Only entries recorded after the entry with this ID
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// parseLedgerFilter reads a ledger filter from query parameters
+func parseLedgerFilter(query url.Values) (LedgerFilter, error) {
+	filter := LedgerFilter{
+		Category:   httptypes.TransactionCategory(query.Get("category")),
+		Attraction: query.Get("attraction"),
+		Instance:   query.Get("instance"),
+	}
+
+	var err error
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return filter, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+
+	if until := query.Get("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return filter, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			return filter, fmt.Errorf("invalid limit: %s", limit)
+		}
+	}
+
+	return filter, nil
+}
+
+// matches returns whether the entry is selected by the filter
+func (f LedgerFilter) matches(entry httptypes.LedgerEntry) bool {
+	if entry.ID <= f.AfterID {
+		return false
+	}
+	if f.Category != "" && entry.Category != f.Category {
+		return false
+	}
+	if f.Attraction != "" && entry.Attraction != f.Attraction {
+		return false
+	}
+	if f.Instance != "" && entry.Instance != f.Instance {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// newLedgerEntry builds the double-entry record for a signed amount
+func newLedgerEntry(category httptypes.TransactionCategory, attraction string, instance string, amount float64) httptypes.LedgerEntry {
+	entry := httptypes.LedgerEntry{
+		Category:   category,
+		Attraction: attraction,
+		Instance:   instance,
+		Debit:      httptypes.AccountCash,
+		Credit:     string(category),
+		Amount:     amount,
+	}
+
+	if amount < 0 {
+		entry.Debit, entry.Credit = entry.Credit, entry.Debit
+		entry.Amount = -amount
+	}
+
+	return entry
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+func TestLedgerFilterMatches(t *testing.T) {
+	noon := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	entry := httptypes.LedgerEntry{
+		ID:         10,
+		Time:       noon,
+		Category:   httptypes.CategoryRideFee,
+		Attraction: "carousel",
+		Instance:   "carousel-1",
+	}
+
+	tests := []struct {
+		name   string
+		filter LedgerFilter
+		want   bool
+	}{
+		{"empty filter", LedgerFilter{}, true},
+		{"after an earlier entry", LedgerFilter{AfterID: 9}, true},
+		{"after the entry itself", LedgerFilter{AfterID: 10}, false},
+		{"same category", LedgerFilter{Category: httptypes.CategoryRideFee}, true},
+		{"other category", LedgerFilter{Category: httptypes.CategoryRefund}, false},
+		{"same attraction", LedgerFilter{Attraction: "carousel"}, true},
+		{"other attraction", LedgerFilter{Attraction: "restroom"}, false},
+		{"same instance", LedgerFilter{Instance: "carousel-1"}, true},
+		{"other instance", LedgerFilter{Instance: "carousel-2"}, false},
+		{"since the entry", LedgerFilter{Since: noon}, true},
+		{"since after the entry", LedgerFilter{Since: noon.Add(time.Second)}, false},
+		{"until after the entry", LedgerFilter{Until: noon.Add(time.Second)}, true},
+		{"until the entry", LedgerFilter{Until: noon}, false},
+		{"all matching", LedgerFilter{Category: httptypes.CategoryRideFee, Instance: "carousel-1", Since: noon.Add(-time.Hour), Until: noon.Add(time.Hour)}, true},
+		{"one not matching", LedgerFilter{Category: httptypes.CategoryRideFee, Instance: "carousel-2"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(entry); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLedgerFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    LedgerFilter
+		wantErr bool
+	}{
+		{
+			name:  "no filters",
+			query: "",
+			want:  LedgerFilter{},
+		},
+		{
+			name:  "all filters",
+			query: "category=upkeep&attraction=carousel&instance=carousel-1&since=2025-06-01T00:00:00Z&until=2025-06-02T00:00:00Z&limit=5",
+			want: LedgerFilter{
+				Category:   httptypes.CategoryUpkeep,
+				Attraction: "carousel",
+				Instance:   "carousel-1",
+				Since:      time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+				Until:      time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+				Limit:      5,
+			},
+		},
+		{name: "invalid since", query: "since=yesterday", wantErr: true},
+		{name: "invalid until", query: "until=tomorrow", wantErr: true},
+		{name: "invalid limit", query: "limit=many", wantErr: true},
+		{name: "negative limit", query: "limit=-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := parseLedgerFilter(query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLedgerFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseLedgerFilter() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLedgerEntry(t *testing.T) {
+	tests := []struct {
+		name       string
+		amount     float64
+		wantDebit  string
+		wantCredit string
+		wantAmount float64
+	}{
+		{"money in", 5, httptypes.AccountCash, string(httptypes.CategoryRideFee), 5},
+		{"money out", -5, string(httptypes.CategoryRideFee), httptypes.AccountCash, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := newLedgerEntry(httptypes.CategoryRideFee, "carousel", "carousel-1", tt.amount)
+			if entry.Debit != tt.wantDebit || entry.Credit != tt.wantCredit || entry.Amount != tt.wantAmount {
+				t.Errorf("newLedgerEntry() = debit %s, credit %s, amount %v, want debit %s, credit %s, amount %v",
+					entry.Debit, entry.Credit, entry.Amount, tt.wantDebit, tt.wantCredit, tt.wantAmount)
+			}
+			if entry.Net() != tt.amount {
+				t.Errorf("Net() = %v, want %v", entry.Net(), tt.amount)
+			}
+		})
+	}
+}
+
+func TestCheckAttractionTransaction(t *testing.T) {
+	tests := []struct {
+		category httptypes.TransactionCategory
+		amount   float64
+		wantErr  bool
+	}{
+		{httptypes.CategoryRideFee, 5, false},
+		{httptypes.CategoryRideFee, 0, false},
+		{httptypes.CategoryRideFee, -5, true},
+		{httptypes.CategoryRefund, -5, false},
+		{httptypes.CategoryRefund, 5, true},
+		{httptypes.CategoryUpkeep, -1, false},
+		{httptypes.CategoryUpkeep, 1000, true},
+		{httptypes.CategoryBuild, -100, false},
+		{httptypes.CategoryRepair, -50, false},
+		{httptypes.CategoryRepair, 50, true},
+		{httptypes.CategoryLoan, 1000, true},
+		{httptypes.CategoryEntranceFee, 10, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.category, tt.amount), func(t *testing.T) {
+			err := checkAttractionTransaction(tt.category, tt.amount)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkAttractionTransaction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetLedgerLimit(t *testing.T) {
+	state, err := NewStateManager(&Config{}, &Scenario{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, amount := range []float64{1, -2, 3, -4} {
+		if _, err := state.Record(httptypes.CategoryRideFee, "carousel", "carousel-1", amount); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		filter  LedgerFilter
+		wantIDs []int
+	}{
+		{"everything", LedgerFilter{}, []int{1, 2, 3, 4}},
+		{"most recent", LedgerFilter{Limit: 2}, []int{3, 4}},
+		{"after an entry", LedgerFilter{AfterID: 2}, []int{3, 4}},
+		{"limit above count", LedgerFilter{AfterID: 3, Limit: 5}, []int{4}},
+		{"nothing left", LedgerFilter{AfterID: 4}, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := state.GetLedger(tt.filter)
+			ids := make([]int, 0, len(entries))
+			for _, entry := range entries {
+				ids = append(ids, entry.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("GetLedger() IDs = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+
+	if money := state.GetMoney(); money != -2 {
+		t.Errorf("GetMoney() = %v, want -2", money)
+	}
+}
+
+func TestRecordKeepsUnreportedEntries(t *testing.T) {
+	state, err := NewStateManager(&Config{}, &Scenario{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A busy day's entries are all kept for its report
+	for range maxLedgerEntries + 10 {
+		if _, err := state.Record(httptypes.CategoryRideFee, "carousel", "carousel-1", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entries := state.GetLedger(LedgerFilter{}); len(entries) != maxLedgerEntries+10 || entries[0].ID != 1 {
+		t.Fatalf("GetLedger() kept %d entries from ID %d, want all %d", len(entries), entries[0].ID, maxLedgerEntries+10)
+	}
+
+	// Once reported, the oldest entries are trimmed again
+	if err := state.AddDayReport(httptypes.DayReport{Day: 1, LastLedgerID: maxLedgerEntries + 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := state.Record(httptypes.CategoryRideFee, "carousel", "carousel-1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if entries := state.GetLedger(LedgerFilter{}); len(entries) != maxLedgerEntries || entries[0].ID != 12 {
+		t.Errorf("GetLedger() kept %d entries from ID %d, want %d from ID 12", len(entries), entries[0].ID, maxLedgerEntries)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+
+	"kubepark/pkg/httptypes"
+)
+
+// LoanTerms are the conditions the bank lends money under
+type LoanTerms struct {
+	CreditLimit float64 `json:"credit_limit"` // Most the park may owe at once
+	DailyRate   float64 `json:"daily_rate"`   // Interest charged per simulated day
+}
+
+// debt returns the sum of all loan balances
+func (state *ParkState) debt() float64 {
+	debt := 0.0
+	for _, loan := range state.Loans {
+		debt += loan.Balance
+	}
+	return debt
+}
+
+// takeLoan borrows money from the bank. It must only be called while updating the state.
+func (state *ParkState) takeLoan(amount float64, terms LoanTerms) (httptypes.Loan, httptypes.LedgerEntry, error) {
+	if state.Bankrupt {
+		return httptypes.Loan{}, httptypes.LedgerEntry{}, fmt.Errorf("the park is bankrupt")
+	}
+	if amount <= 0 {
+		return httptypes.Loan{}, httptypes.LedgerEntry{}, fmt.Errorf("amount must be positive")
+	}
+	if state.debt()+amount > terms.CreditLimit {
+		return httptypes.Loan{}, httptypes.LedgerEntry{}, fmt.Errorf("loan would exceed the credit limit of $%.2f", terms.CreditLimit)
+	}
+
+	state.NextLoanID++
+	loan := httptypes.Loan{
+		ID:        state.NextLoanID,
+		Principal: amount,
+		Balance:   amount,
+		Rate:      terms.DailyRate,
+		TakenAt:   state.CurrentTime,
+	}
+	state.Loans = append(state.Loans, loan)
+
+	entry := state.record(httptypes.CategoryLoan, "", "", amount)
+	return loan, entry, nil
+}
+
+// repayLoan pays back some or all of a loan. It must only be called while updating the state.
+func (state *ParkState) repayLoan(id int, amount float64) (httptypes.LedgerEntry, error) {
+	for i, loan := range state.Loans {
+		if loan.ID != id {
+			continue
+		}
+
+		if amount == 0 {
+			amount = loan.Balance
+		}
+		if amount < 0 || amount > loan.Balance {
+			return httptypes.LedgerEntry{}, fmt.Errorf("amount must be between $0 and the balance of $%.2f", loan.Balance)
+		}
+		if amount > state.Money {
+			return httptypes.LedgerEntry{}, fmt.Errorf("not enough money to repay $%.2f", amount)
+		}
+
+		state.Loans[i].Balance -= amount
+		if state.Loans[i].Balance <= 0 {
+			state.Loans = append(state.Loans[:i], state.Loans[i+1:]...)
+		}
+
+		return state.record(httptypes.CategoryLoanRepayment, "", "", -amount), nil
+	}
+
+	return httptypes.LedgerEntry{}, fmt.Errorf("loan %d not found", id)
+}
+
+// chargeInterest charges a day of interest on every loan. It must only be called while updating the state.
+func (state *ParkState) chargeInterest() []httptypes.LedgerEntry {
+	var entries []httptypes.LedgerEntry
+	for _, loan := range state.Loans {
+		if interest := loan.Balance * loan.Rate; interest > 0 {
+			entries = append(entries, state.record(httptypes.CategoryInterest, "", "", -interest))
+		}
+	}
+	return entries
+}
+
+// checkBankruptcy declares the park bankrupt when it's in the red and can't borrow its way out.
+// It must only be called while updating the state.
+func (state *ParkState) checkBankruptcy(terms LoanTerms) bool {
+	if state.Money < 0 && terms.CreditLimit-state.debt() < -state.Money {
+		state.Bankrupt = true
+	}
+	return state.Bankrupt
+}
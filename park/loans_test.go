@@ -0,0 +1,157 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"kubepark/pkg/httptypes"
+)
+
+func TestTakeLoan(t *testing.T) {
+	terms := LoanTerms{CreditLimit: 1000, DailyRate: 0.01}
+
+	tests := []struct {
+		name     string
+		state    ParkState
+		amount   float64
+		wantErr  bool
+		wantDebt float64
+	}{
+		{name: "within the limit", amount: 400, wantDebt: 400},
+		{name: "up to the limit", amount: 1000, wantDebt: 1000},
+		{name: "over the limit", amount: 1001, wantErr: true},
+		{name: "over the limit with debt", state: ParkState{Loans: []httptypes.Loan{{Balance: 700}}}, amount: 400, wantErr: true, wantDebt: 700},
+		{name: "zero", amount: 0, wantErr: true},
+		{name: "negative", amount: -10, wantErr: true},
+		{name: "bankrupt", state: ParkState{Bankrupt: true}, amount: 10, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+			money := state.Money
+
+			_, entry, err := state.takeLoan(tt.amount, terms)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("takeLoan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := state.debt(); got != tt.wantDebt {
+				t.Errorf("debt() = %v, want %v", got, tt.wantDebt)
+			}
+			if tt.wantErr {
+				if state.Money != money {
+					t.Errorf("Money = %v after a refused loan, want %v", state.Money, money)
+				}
+				return
+			}
+			if entry.Category != httptypes.CategoryLoan || entry.Net() != tt.amount || state.Money != money+tt.amount {
+				t.Errorf("takeLoan() recorded %s of %v, money %v", entry.Category, entry.Net(), state.Money)
+			}
+		})
+	}
+}
+
+func TestRepayLoan(t *testing.T) {
+	tests := []struct {
+		name        string
+		money       float64
+		id          int
+		amount      float64
+		wantErr     bool
+		wantBalance float64 // Balance left on the loan, -1 if it's paid off
+	}{
+		{name: "part", money: 1000, id: 1, amount: 200, wantBalance: 300},
+		{name: "everything", money: 1000, id: 1, amount: 500, wantBalance: -1},
+		{name: "the whole balance by default", money: 1000, id: 1, amount: 0, wantBalance: -1},
+		{name: "more than the balance", money: 1000, id: 1, amount: 600, wantErr: true, wantBalance: 500},
+		{name: "more than the park has", money: 100, id: 1, amount: 200, wantErr: true, wantBalance: 500},
+		{name: "negative", money: 1000, id: 1, amount: -1, wantErr: true, wantBalance: 500},
+		{name: "unknown loan", money: 1000, id: 2, amount: 100, wantErr: true, wantBalance: 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := ParkState{
+				Money: tt.money,
+				Loans: []httptypes.Loan{{ID: 1, Principal: 500, Balance: 500}},
+			}
+
+			_, err := state.repayLoan(tt.id, tt.amount)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("repayLoan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			balance := -1.0
+			if len(state.Loans) > 0 {
+				balance = state.Loans[0].Balance
+			}
+			if balance != tt.wantBalance {
+				t.Errorf("balance = %v, want %v", balance, tt.wantBalance)
+			}
+		})
+	}
+}
+
+func TestChargeInterest(t *testing.T) {
+	tests := []struct {
+		name      string
+		loans     []httptypes.Loan
+		wantTotal float64
+		wantCount int
+	}{
+		{name: "no loans"},
+		{name: "one loan", loans: []httptypes.Loan{{Balance: 1000, Rate: 0.01}}, wantTotal: 10, wantCount: 1},
+		{name: "two loans", loans: []httptypes.Loan{{Balance: 1000, Rate: 0.01}, {Balance: 200, Rate: 0.05}}, wantTotal: 20, wantCount: 2},
+		{name: "interest free", loans: []httptypes.Loan{{Balance: 1000, Rate: 0}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := ParkState{Money: 100, Loans: tt.loans}
+
+			entries := state.chargeInterest()
+			if len(entries) != tt.wantCount {
+				t.Fatalf("chargeInterest() recorded %d entries, want %d", len(entries), tt.wantCount)
+			}
+
+			total := 0.0
+			for _, entry := range entries {
+				if entry.Category != httptypes.CategoryInterest {
+					t.Errorf("entry category = %s, want %s", entry.Category, httptypes.CategoryInterest)
+				}
+				total -= entry.Net()
+			}
+			if math.Abs(total-tt.wantTotal) > 1e-9 {
+				t.Errorf("interest = %v, want %v", total, tt.wantTotal)
+			}
+			if math.Abs(state.Money-(100-tt.wantTotal)) > 1e-9 {
+				t.Errorf("Money = %v, want %v", state.Money, 100-tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestCheckBankruptcy(t *testing.T) {
+	terms := LoanTerms{CreditLimit: 1000}
+
+	tests := []struct {
+		name  string
+		state ParkState
+		want  bool
+	}{
+		{name: "in the black", state: ParkState{Money: 10}, want: false},
+		{name: "in the red but can borrow", state: ParkState{Money: -500}, want: false},
+		{name: "in the red up to the credit left", state: ParkState{Money: -400, Loans: []httptypes.Loan{{Balance: 600}}}, want: false},
+		{name: "in the red beyond the credit left", state: ParkState{Money: -500, Loans: []httptypes.Loan{{Balance: 600}}}, want: true},
+		{name: "already bankrupt", state: ParkState{Money: 10, Bankrupt: true}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+			if got := state.checkBankruptcy(terms); got != tt.want {
+				t.Errorf("checkBankruptcy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,71 +2,183 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"kubepark/pkg/k8s"
 	"kubepark/pkg/logger"
+	"kubepark/pkg/settings"
+	"kubepark/pkg/tracing"
 	"log/slog"
-	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// shutdownTimeout is how long requests in progress get to finish when the park shuts down
+const shutdownTimeout = 10 * time.Second
+
 // Park represents the amusement park simulator
 type Park struct {
 	Config        *Config
 	MetricsServer *http.Server
 	MainServer    *http.Server
 	State         *StateManager
-	GuestManager  *GuestJobManager
+	Clock         Clock
+	Guests        *GuestRegistry
+	Staff         *StaffRegistry
+	Attractions   *AttractionDirectory
+	Discovery     *k8s.Discovery
+	Arrivals      ArrivalModel
+	Weather       *WeatherGenerator
+	Calendar      *Calendar
+	Scenario      *Scenario
+	Settings      *settings.Watcher
+	Spawner       GuestSpawner
 	GrafanaLive   *GrafanaLiveClient
+
+	lease           *leadership
+	shutdownTracing func() error
 }
 
-// New creates a new park simulator
-func New() *Park {
+// New creates a new park simulator once this replica is elected leader, or exits if the context
+// is done first
+func New(ctx context.Context) *Park {
 	config := &Config{}
 
 	RegisterFlags(config)
 
-	// Initialize logger with configured level
-	logger.InitLogger(config.LogLevel)
+	// Initialize logger with configured level and format
+	logger.Init(logger.Options{
+		Level:     config.LogLevel,
+		Format:    config.LogFormat,
+		Component: "park",
+		Attrs:     []any{"instance", config.Identity},
+	})
+
+	// Connect to the cluster
+	clientset, err := k8s.NewClient()
+	if err != nil {
+		slog.Error("Failed to create Kubernetes client", "error", err)
+		panic(err)
+	}
 
-	// Check for existing park service
-	if err := checkForExistingPark(); err != nil {
-		slog.Error("Failed park check", "error", err)
+	// Wait to be elected leader, so only one replica runs the park at a time
+	lease, err := campaign(ctx, clientset, config.LeaseNamespace, config.Identity)
+	if errors.Is(err, context.Canceled) {
+		slog.Info("Stopped standing by for park leadership")
+		os.Exit(0)
+	}
+	if err != nil {
+		slog.Error("Failed to elect park leader", "error", err)
 		panic(err)
 	}
 
-	// Initialize game state
-	state, err := NewStateManager(config)
+	// Trace guests' visits through the park
+	shutdownTracing, err := tracing.Init(ctx, "park")
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "error", err)
+		panic(err)
+	}
+
+	// Load the scenario to play
+	scenario, err := LoadScenario(config.Mode, config.ScenarioPath)
+	if err != nil {
+		slog.Error("Failed to load scenario", "error", err)
+		panic(err)
+	}
+	if config.ArrivalModel != "" {
+		scenario.Guests.ArrivalModel = config.ArrivalModel
+	}
+	slog.Info("Playing scenario", "name", scenario.Name, "objectives", len(scenario.Objectives), "time_limit_days", scenario.TimeLimitDays)
+
+	// Initialize game state, which is saved before stepping down if the lease is lost
+	state, err := NewStateManager(config, scenario)
 	if err != nil {
 		slog.Error("Failed to initialize game state", "error", err)
 		panic(err)
 	}
+	lease.Guard(state)
+
+	// Apply the settings file on top of the flags, and keep watching it for changes
+	settingsApplier := newSettingsApplier(config, state)
+	settingsWatcher := settings.NewWatcher(config.SettingsPath, settings.Interval, settingsApplier.Apply)
+	if err := settingsWatcher.Load(); err != nil {
+		slog.Error("Failed to apply settings file, falling back to flags", "path", config.SettingsPath, "error", err)
+		if err := settingsApplier.Apply(nil); err != nil {
+			slog.Error("Failed to apply settings", "error", err)
+			panic(err)
+		}
+	}
+
+	// Initialize simulation clock
+	clock := NewSimClock(state, config.TimeScale)
+	logger.SetSimTime(clock.Now())
+
+	// Initialize guest and attraction tracking
+	guests := NewGuestRegistry()
+	staff := NewStaffRegistry()
+	attractions := NewAttractionDirectory()
 
-	// Initialize guest job manager
-	guestManager, err := NewGuestJobManager()
+	// Initialize guest arrival model
+	arrivalModel, err := NewArrivalModel(scenario.Guests)
 	if err != nil {
-		slog.Error("Failed to initialize guest job manager", "error", err)
+		slog.Error("Failed to initialize arrival model", "error", err)
+		panic(err)
+	}
+
+	// Initialize the weather, which stays the same across restarts
+	weatherSeed := scenario.Weather.Seed
+	if weatherSeed == 0 {
+		weatherSeed, err = state.GetWeatherSeed()
+		if err != nil {
+			slog.Error("Failed to pick weather seed", "error", err)
+			panic(err)
+		}
+	}
+	weather := NewWeatherGenerator(scenario.Weather, weatherSeed)
+
+	// Initialize the calendar of weekdays, seasons and holidays
+	calendar := NewCalendar(scenario.Calendar)
+
+	// Watch the attraction pods in the cluster
+	discovery, err := k8s.NewDiscovery(clientset)
+	if err != nil {
+		slog.Error("Failed to initialize attraction discovery", "error", err)
+		panic(err)
+	}
+
+	// Initialize transaction authentication, tying credentials to attraction pods
+	authenticator := NewAuthenticator(state, clientset, discovery)
+
+	// Initialize the guest spawner, one job per guest or a crowd of guests
+	spawner, err := NewGuestSpawner(config, clientset, scenario.Guests.MaxGuests)
+	if err != nil {
+		slog.Error("Failed to initialize guest spawner", "error", err)
 		panic(err)
 	}
 
 	// Initialize Grafana Live client
 	grafanaLive := NewGrafanaLiveClient(config.GrafanaURL, config.GrafanaAPIKey)
 
-	metrics.EntranceFee.Set(config.EntranceFee)
-	metrics.IsParkClosed.Set(btof(config.Closed))
-	metrics.OpensAt.Set(float64(config.OpensAt))
-	metrics.ClosesAt.Set(float64(config.ClosesAt))
+	setReputationMetrics(state.GetReputation())
+	metrics.Day.Set(float64(state.GetDay()))
+	setLoanMetrics(state.GetLoans(scenario.Loans))
+	setLandMetrics(state.GetLand(scenario.Land))
+	setWeatherMetrics(weather.On(calendar.DayOf(state.GetSettings(), state.GetTime())))
+	setScenarioMetrics(scenario.status(state.GetScenarioState(), state.GetDay(), objectiveValues(state, attractions)))
 
 	// Create metrics server on port 9000
 	r := prometheus.NewRegistry()
 	RegisterParkMetrics(r)
 	metricsMux := http.NewServeMux()
 	metricsMux.Handle("/metrics", promhttp.HandlerFor(r, promhttp.HandlerOpts{}))
+	metricsMux.HandleFunc("/healthz", handleHealthz)
+	metricsMux.HandleFunc("/log-level", logger.HandleLevel())
 	metricsServer := &http.Server{
 		Addr:    ":9000",
 		Handler: metricsMux,
@@ -74,26 +186,53 @@ func New() *Park {
 
 	// Create main server on port 80
 	mainMux := http.NewServeMux()
-	mainMux.HandleFunc("/park-status", handleStatus(config, state))
-	mainMux.HandleFunc("/transaction", handleTransaction(state))
-	mainMux.HandleFunc("/enter", handleEnter(state))
+	mainMux.HandleFunc("/park-status", handleStatus(state, guests, weather, calendar))
+	mainMux.HandleFunc("/credentials", handleCredentials(authenticator))
+	mainMux.HandleFunc("/transaction", handleTransaction(state, scenario, authenticator))
+	mainMux.HandleFunc("/enter", handleEnter(state, guests, attractions))
+	mainMux.HandleFunc("/exit", handleExit(guests))
+	mainMux.HandleFunc("/staff", handleStaff(staff, scenario.Staff, authenticator))
+	mainMux.HandleFunc("/attractions", handleAttractions(attractions, discovery, authenticator))
+	mainMux.HandleFunc("/visit-report", handleVisitReport(state, guests, attractions))
+	mainMux.HandleFunc("/reports", handleReports(state))
+	mainMux.HandleFunc("/loans", handleLoans(state, scenario.Loans))
+	mainMux.HandleFunc("/loans/repay", handleRepayLoan(state, scenario.Loans))
+	mainMux.HandleFunc("/land", handleLand(state, scenario.Land))
+	mainMux.HandleFunc("/scenario", handleScenario(state, scenario, attractions))
+	mainMux.HandleFunc("/clock", handleClock(state, clock, calendar))
+	mainMux.HandleFunc("/ledger", handleLedger(state))
+	mainMux.HandleFunc("/settings", handleSettings(state))
+	mainMux.HandleFunc("/weather", handleWeather(state, weather, calendar))
+	mainMux.HandleFunc("/calendar", handleCalendar(state, weather, calendar))
 	mainServer := &http.Server{
 		Addr:    ":80",
-		Handler: mainMux,
+		Handler: tracing.Handler(mainMux, "park"),
 	}
 
 	return &Park{
-		Config:        config,
-		MetricsServer: metricsServer,
-		MainServer:    mainServer,
-		State:         state,
-		GuestManager:  guestManager,
-		GrafanaLive:   grafanaLive,
+		Config:          config,
+		MetricsServer:   metricsServer,
+		MainServer:      mainServer,
+		State:           state,
+		Clock:           clock,
+		Guests:          guests,
+		Staff:           staff,
+		Attractions:     attractions,
+		Discovery:       discovery,
+		Arrivals:        arrivalModel,
+		Weather:         weather,
+		Calendar:        calendar,
+		Scenario:        scenario,
+		Settings:        settingsWatcher,
+		Spawner:         spawner,
+		GrafanaLive:     grafanaLive,
+		lease:           lease,
+		shutdownTracing: shutdownTracing,
 	}
 }
 
 // Start starts the park simulator
-func (p *Park) Start() error {
+func (p *Park) Start(ctx context.Context) error {
 	// Start metrics server
 	go func() {
 		slog.Info("Starting metrics server on port 9000")
@@ -103,114 +242,297 @@ func (p *Park) Start() error {
 		}
 	}()
 
+	// Apply changes to the settings file while the park runs
+	p.Settings.Start(ctx)
+
+	// Keep the attraction directory in step with the attraction pods
+	p.Attractions.Watch(p.Discovery)
+	if err := p.Discovery.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start attraction discovery: %v", err)
+	}
+
+	// Stream to Grafana Live without holding up the simulation loop
+	p.GrafanaLive.Start(ctx)
+
+	// Keep count of the guests sent into the park
+	if err := p.Spawner.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start guest spawner: %v", err)
+	}
+
 	// Start the park simulation loop
+	loopDone := make(chan struct{})
 	go func() {
+		defer close(loopDone)
+
 		slog.Info("Starting park simulation loop")
 		ticker := time.NewTicker(time.Second)
 		defer ticker.Stop()
 
-		ctx := context.Background()
-
-		for range ticker.C {
-			time := p.State.GetTime().Add(time.Second * 100)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
 
-			// Speed up simulation time
-			err := p.State.SetTime(time)
+			// Advance simulation time, which does nothing while the clock is paused
+			elapsed, err := p.Clock.Advance(time.Second)
 			if err != nil {
-				slog.Error("Failed to set time", "error", err)
+				slog.Error("Failed to advance clock", "error", err)
+			}
+
+			time := p.Clock.Now()
+			clock := p.Clock.Status()
+
+			// Logs are stamped with the time set here rather than asking the clock, which
+			// would take the state lock that logging code may be holding
+			logger.SetSimTime(time)
+
+			// Forget guests that never said goodbye
+			if expired := p.Guests.Expire(); expired > 0 {
+				slog.Warn("Expired guests that never left", "count", expired)
+			}
+			guests := p.Guests.Count()
+
+			// Take staff off duty that stopped showing up
+			if expired := p.Staff.Expire(); expired > 0 {
+				slog.Warn("Staff went off duty without notice", "count", expired)
+			}
+			setStaffMetrics(p.Staff.Count(), p.Staff.List(p.Scenario.Staff))
+
+			// Drop attractions that stopped sending heartbeats
+			if expired := p.Attractions.Expire(); expired > 0 {
+				slog.Warn("Attractions stopped sending heartbeats", "count", expired)
 			}
+			metrics.RegisteredAttractions.Set(float64(len(p.Attractions.List())))
+
+			settings := p.State.GetSettings()
+			day := p.Calendar.DayOf(settings, time)
+			weather := p.Weather.On(day)
 
 			// Update metrics
 			metrics.Time.Set(float64(time.Unix()))
 			metrics.Money.Set(p.State.GetMoney())
-
-			// Push to Grafana Live
-			if err := p.GrafanaLive.PushMetric("park_time", float64(time.Unix()*1000), nil); err != nil {
-				slog.Warn("Failed to push time metric to Grafana Live", "error", err)
+			metrics.ClockPaused.Set(btof(clock.Paused))
+			metrics.ClockSpeed.Set(clock.Speed)
+			metrics.Guests.Set(float64(guests))
+			setWeatherMetrics(weather)
+			setCalendarMetrics(p.Calendar.Day(settings, p.Weather, day))
+			setGuestMetrics(p.Spawner.Phases())
+
+			// Publish to Grafana Live, which sends the points in the background
+			p.GrafanaLive.Publish("park_time", float64(time.Unix()*1000), nil)
+			p.GrafanaLive.Publish("park_money", p.State.GetMoney(), nil)
+			p.GrafanaLive.Publish("park_guests", float64(guests), nil)
+
+			// Nothing happens in the park while the clock is paused
+			if elapsed == 0 {
+				continue
 			}
-			if err := p.GrafanaLive.PushMetric("park_money", p.State.GetMoney(), nil); err != nil {
-				slog.Warn("Failed to push money metric to Grafana Live", "error", err)
+
+			p.closeDays(time)
+
+			// A bankrupt park gets no more guests
+			if p.State.IsBankrupt() {
+				continue
 			}
 
-			if isClosed(p.Config, time) {
-				foundJobs, err := p.GuestManager.CleanupJobs(ctx)
+			p.payStaff(time, elapsed)
+
+			if p.Calendar.IsClosed(settings, time) {
+				sentHome, err := p.Spawner.SendHome(ctx)
 				if err != nil {
-					slog.Error("Failed to cleanup all jobs during closed hours", "error", err)
+					slog.Error("Failed to send guests home during closed hours", "error", err)
 				}
 
-				if foundJobs > 0 {
-					slog.Info("Removed guests after park closed", "count", foundJobs)
+				if sentHome > 0 {
+					slog.Info("Removed guests after park closed", "count", sentHome)
 				}
 
+				p.Guests.Clear()
+
 				continue
 			}
 
-			// Randomly decide whether to create a new guest (10% chance)
-			if rand.Float64() < 0.1 {
-				// Create new guests if park is open and not at capacity
-				url := p.Config.SelfURL
-				if url == "" {
-					url = "http://park:80"
+			// Decide how many guests arrive during the elapsed time, fewer in bad weather
+			// and more on weekends, in high season and on holidays
+			rate := p.Arrivals.Rate(ArrivalContext{
+				Time:        time,
+				EntranceFee: p.State.GetEntranceFee(),
+				Attractions: p.Attractions.List(),
+				Reputation:  p.State.GetReputation().Park.Score,
+			}) * weatherArrivalFactor(weather) * p.Calendar.DemandFactor(day)
+			metrics.ArrivalRate.Set(rate)
+
+			visit := GuestVisit{
+				Scenario: p.Scenario.Name,
+				Day:      p.State.GetDay(),
+				Arrival:  time,
+				Money:    p.Scenario.Guests.Money,
+			}
+			for range arrivals(rate * elapsed.Hours()) {
+				err := p.Spawner.Spawn(ctx, visit)
+				if errors.Is(err, errParkFull) {
+					metrics.GuestsTurnedAway.Inc()
+					continue
 				}
-
-				if err := p.GuestManager.CreateGuestJob(ctx, p.Config.Image, url); err != nil {
-					slog.Warn("Failed to create guest job", "error", err)
+				if err != nil {
+					metrics.GuestsNotSpawned.Inc()
+					slog.Warn("Failed to spawn guest", "error", err)
 				}
 			}
 		}
 	}()
 
 	// Start main server
-	slog.Info("Starting main server on port 80")
-	return p.MainServer.ListenAndServe()
-}
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting main server on port 80")
+		serveErr <- p.MainServer.ListenAndServe()
+	}()
 
-// Stop gracefully stops the park simulator
-func (p *Park) Stop() error {
-	if err := p.MetricsServer.Close(); err != nil {
+	select {
+	case err := <-serveErr:
 		return err
+	case <-ctx.Done():
 	}
-	return p.MainServer.Close()
+
+	slog.Info("Shutting down park")
+	<-loopDone
+	return p.Stop()
 }
 
-// btof converts a bool to a float64 (0 or 1)
-func btof(b bool) float64 {
-	if b {
-		return 1
+// closeDays reports on every day whose closing time passed since the current day started.
+// Fast-forwarding can skip several closing times at once.
+func (p *Park) closeDays(now time.Time) {
+	for {
+		start := p.State.GetDayStart()
+		settings := p.State.GetSettings()
+		end := p.Calendar.NextClosing(settings, start)
+		if end.After(now) {
+			return
+		}
+
+		// Charge the day's interest before summing up the day
+		wasBankrupt := p.State.IsBankrupt()
+		charges, bankrupt, err := p.State.CloseDayFinances(p.Scenario.Loans)
+		if err != nil {
+			slog.Error("Failed to close day finances", "error", err)
+			return
+		}
+		for _, entry := range charges {
+			observeEntry(entry)
+		}
+		if bankrupt && !wasBankrupt {
+			slog.Warn("The park went bankrupt, game over", "money", p.State.GetMoney())
+		}
+
+		// The day covers every transaction since the previous day was closed out
+		lastLedgerID := p.State.GetDayLedgerID()
+		report := buildDayReport(
+			p.State.GetDay(),
+			start,
+			end,
+			lastLedgerID,
+			p.State.GetLedger(LedgerFilter{AfterID: lastLedgerID}),
+			p.State.GetMoney(),
+			p.State.GetReputation().Park.Score,
+		)
+		// The day is the one that just closed, even if it closed after midnight
+		day := p.Calendar.DayOf(settings, end.Add(-time.Minute))
+		report.Weather = p.Weather.On(day).Condition
+		if holiday, ok := p.Calendar.holiday(day); ok {
+			report.Holiday = holiday.Name
+		}
+
+		if err := p.State.AddDayReport(report); err != nil {
+			slog.Error("Failed to store day report", "day", report.Day, "error", err)
+			return
+		}
+
+		// See how the park is doing on its objectives
+		values := objectiveValues(p.State, p.Attractions)
+		progress := p.Scenario.evaluate(p.State.GetScenarioState(), report.Day, values, p.State.IsBankrupt())
+		if err := p.State.SetScenarioState(progress); err != nil {
+			slog.Error("Failed to store scenario progress", "error", err)
+		}
+		setScenarioMetrics(p.Scenario.status(progress, report.Day+1, values))
+		if progress.Outcome != OutcomeInProgress {
+			slog.Info("Scenario is over", "scenario", p.Scenario.Name, "outcome", progress.Outcome, "day", report.Day)
+		}
+
+		metrics.Day.Set(float64(report.Day + 1))
+		metrics.NetProfit.Set(report.NetProfit)
+		setLoanMetrics(p.State.GetLoans(p.Scenario.Loans))
+
+		slog.Info("Closed out the day",
+			"day", report.Day,
+			"guests", report.Guests,
+			"weather", report.Weather,
+			"revenue", report.TotalRevenue,
+			"costs", report.TotalCosts,
+			"net_profit", report.NetProfit)
 	}
-	return 0
 }
 
-func main() {
-	park := New()
-	if err := park.Start(); err != nil {
-		slog.Error("Park failed to start", "error", err)
-		panic(err)
+// payStaff charges the wages staff earned during the elapsed time, once every simulated hour
+func (p *Park) payStaff(now time.Time, elapsed time.Duration) {
+	earned := p.Staff.Accrue(elapsed, p.Scenario.Staff)
+
+	entry, paid, err := p.State.PayStaff(now, earned)
+	if err != nil {
+		slog.Error("Failed to pay staff", "error", err)
+	}
+	if paid {
+		observeEntry(entry)
 	}
 }
 
-// checkForExistingPark checks if another park service exists in the cluster
-func checkForExistingPark() error {
-	var parks []interface{}
+// Stop drains requests in progress, stops both HTTP servers, saves the final state
+// and hands leadership over to the standby
+func (p *Park) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	decoder := func(r io.Reader, v *[]interface{}, ip string) error {
-		*v = append(*v, true)
-		return nil
+	var errs []error
+	if err := p.MainServer.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
 	}
-
-	err := k8s.DiscoverServices("/park-status", &parks, decoder)
-	if err != nil {
-		return err
+	if err := p.MetricsServer.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := p.State.Flush(); err != nil {
+		errs = append(errs, err)
 	}
 
-	for len(parks) > 0 {
-		return fmt.Errorf("another park service is already running in the cluster")
+	// Only let a standby take over once the state is saved
+	p.lease.Release()
+
+	// The last points for Grafana Live and the last spans don't need to hold up the standby
+	p.GrafanaLive.Wait()
+	if err := p.shutdownTracing(); err != nil {
+		errs = append(errs, err)
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// btof converts a bool to a float64 (0 or 1)
+func btof(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
 }
 
-func isClosed(config *Config, time time.Time) bool {
-	hour := time.Hour()
-	return config.Closed || hour < config.OpensAt || hour >= config.ClosesAt
+func main() {
+	// Stop on SIGTERM, letting requests in progress finish first
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	park := New(ctx)
+	if err := park.Start(ctx); err != nil {
+		slog.Error("Park failed to start", "error", err)
+		panic(err)
+	}
 }
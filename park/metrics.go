@@ -1,18 +1,50 @@
 package main
 
 import (
+	"kubepark/pkg/httptypes"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // ParkMetrics contains all metrics specific to the main park simulator
 var metrics = struct {
-	Money        prometheus.Gauge
-	Time         prometheus.Gauge
-	EntranceFee  prometheus.Gauge
-	OpensAt      prometheus.Gauge
-	ClosesAt     prometheus.Gauge
-	IsParkClosed prometheus.Gauge
-	Guests       prometheus.Gauge
+	Money                 prometheus.Gauge
+	Time                  prometheus.Gauge
+	EntranceFee           prometheus.Gauge
+	OpensAt               prometheus.Gauge
+	ClosesAt              prometheus.Gauge
+	IsParkClosed          prometheus.Gauge
+	Guests                prometheus.Gauge
+	ClockPaused           prometheus.Gauge
+	ClockSpeed            prometheus.Gauge
+	MoneyFlow             *prometheus.CounterVec
+	ArrivalRate           prometheus.Gauge
+	Rating                prometheus.Gauge
+	Attractions           *prometheus.GaugeVec
+	RegisteredAttractions prometheus.Gauge
+	VisitReports          prometheus.Counter
+	Day                   prometheus.Gauge
+	NetProfit             prometheus.Gauge
+	Debt                  prometheus.Gauge
+	Bankrupt              prometheus.Gauge
+	Objectives            *prometheus.GaugeVec
+	Outcome               *prometheus.GaugeVec
+	SettingsReloads       *prometheus.CounterVec
+	Weather               *prometheus.GaugeVec
+	WeatherFactor         prometheus.Gauge
+	DemandFactor          prometheus.Gauge
+	Staff                 *prometheus.GaugeVec
+	Payroll               prometheus.Gauge
+	TotalSpace            prometheus.Gauge
+	LandPrice             prometheus.Gauge
+	GuestJobs             *prometheus.GaugeVec
+	GuestsTurnedAway      prometheus.Counter
+	GuestsNotSpawned      prometheus.Counter
+	LivePoints            *prometheus.CounterVec
+	LivePushes            *prometheus.CounterVec
+	LiveBuffered          prometheus.Gauge
+	LiveBackoff           prometheus.Gauge
+	LivePushDuration      prometheus.Histogram
 }{
 	Money: prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "park_money",
@@ -31,12 +63,12 @@ var metrics = struct {
 
 	OpensAt: prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "park_opens_at",
-		Help: "Hour at which the park opens",
+		Help: "Hour at which the park opens today",
 	}),
 
 	ClosesAt: prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "park_closes_at",
-		Help: "Hour at which the park closes",
+		Help: "Hour at which the park closes today",
 	}),
 
 	IsParkClosed: prometheus.NewGauge(prometheus.GaugeOpts{
@@ -48,6 +80,187 @@ var metrics = struct {
 		Name: "park_guests",
 		Help: "Current number of guests in the park",
 	}),
+
+	ClockPaused: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_clock_paused",
+		Help: "Whether the simulation clock is paused (1) or running (0)",
+	}),
+
+	ClockSpeed: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_clock_speed",
+		Help: "Speed multiplier of the simulation clock",
+	}),
+
+	MoneyFlow: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "park_money_flow_total",
+			Help: "Total money moved in or out of the park by transaction category",
+		},
+		[]string{"category", "direction"},
+	),
+
+	ArrivalRate: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_arrival_rate",
+		Help: "Expected guest arrivals per simulated hour",
+	}),
+
+	Rating: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_rating",
+		Help: "Park rating from 0 to 5 stars",
+	}),
+
+	Attractions: prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "park_attraction_rating",
+			Help: "Attraction rating from 0 to 5 stars",
+		},
+		[]string{"attraction", "instance"},
+	),
+
+	RegisteredAttractions: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_attractions",
+		Help: "Number of attractions registered with the park",
+	}),
+
+	VisitReports: prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "park_visit_reports_total",
+		Help: "Number of visit reports received from guests",
+	}),
+
+	Day: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_day",
+		Help: "Number of the current day in the park",
+	}),
+
+	NetProfit: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_last_day_net_profit",
+		Help: "Net profit of the last closed day",
+	}),
+
+	Debt: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_debt",
+		Help: "Money the park owes the bank",
+	}),
+
+	Bankrupt: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_bankrupt",
+		Help: "Whether the park is bankrupt (1) or not (0)",
+	}),
+
+	Objectives: prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "park_objective_progress",
+			Help: "Share of a scenario objective's target reached, from 0 to 1",
+		},
+		[]string{"scenario", "objective"},
+	),
+
+	Outcome: prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "park_scenario_outcome",
+			Help: "Outcome of the scenario, 1 for the current outcome and 0 otherwise",
+		},
+		[]string{"scenario", "outcome"},
+	),
+
+	SettingsReloads: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "park_settings_reloads_total",
+			Help: "Number of times the park settings were loaded, by result (applied, invalid)",
+		},
+		[]string{"result"},
+	),
+
+	Weather: prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "park_weather",
+			Help: "Weather in the park, 1 for the current condition and 0 otherwise",
+		},
+		[]string{"condition"},
+	),
+
+	WeatherFactor: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_weather_arrival_factor",
+		Help: "How much the weather scales the guest arrival rate",
+	}),
+
+	DemandFactor: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_calendar_demand_factor",
+		Help: "How much the weekday, season and holidays scale the guest arrival rate",
+	}),
+
+	Staff: prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "park_staff",
+			Help: "Number of staff on duty",
+		},
+		[]string{"role"},
+	),
+
+	Payroll: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_payroll",
+		Help: "Wages of all staff on duty per simulated hour",
+	}),
+
+	TotalSpace: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_total_space",
+		Help: "Land the park owns in acres",
+	}),
+
+	LandPrice: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_land_price",
+		Help: "Price of the next acre of land",
+	}),
+
+	GuestJobs: prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "park_guest_jobs",
+			Help: "Number of guest jobs, or guests of the crowd, in each phase",
+		},
+		[]string{"phase"},
+	),
+
+	GuestsTurnedAway: prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "park_guests_turned_away_total",
+		Help: "Guests that arrived while the park was full",
+	}),
+
+	GuestsNotSpawned: prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "park_guests_not_spawned_total",
+		Help: "Guests that arrived but couldn't be sent into the park, e.g. while the guest crowd was full or down",
+	}),
+
+	LivePoints: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "park_grafana_live_points_total",
+			Help: "Number of points published to Grafana Live, by result (sent, dropped, invalid)",
+		},
+		[]string{"result"},
+	),
+
+	LivePushes: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "park_grafana_live_pushes_total",
+			Help: "Number of batches pushed to Grafana Live, by result (sent, failed)",
+		},
+		[]string{"result"},
+	),
+
+	LiveBuffered: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_grafana_live_buffered",
+		Help: "Points waiting to be sent to Grafana Live",
+	}),
+
+	LiveBackoff: prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "park_grafana_live_backoff_seconds",
+		Help: "How long to wait before trying Grafana Live again, 0 while it's healthy",
+	}),
+
+	LivePushDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "park_grafana_live_push_duration_seconds",
+		Help:    "How long pushing a batch to Grafana Live takes",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 11),
+	}),
 }
 
 // RegisterParkMetrics registers all park-specific metrics
@@ -59,4 +272,100 @@ func RegisterParkMetrics(r *prometheus.Registry) {
 	r.MustRegister(metrics.ClosesAt)
 	r.MustRegister(metrics.IsParkClosed)
 	r.MustRegister(metrics.Guests)
+	r.MustRegister(metrics.ClockPaused)
+	r.MustRegister(metrics.ClockSpeed)
+	r.MustRegister(metrics.MoneyFlow)
+	r.MustRegister(metrics.ArrivalRate)
+	r.MustRegister(metrics.Rating)
+	r.MustRegister(metrics.Attractions)
+	r.MustRegister(metrics.RegisteredAttractions)
+	r.MustRegister(metrics.VisitReports)
+	r.MustRegister(metrics.Day)
+	r.MustRegister(metrics.NetProfit)
+	r.MustRegister(metrics.Debt)
+	r.MustRegister(metrics.Bankrupt)
+	r.MustRegister(metrics.Objectives)
+	r.MustRegister(metrics.Outcome)
+	r.MustRegister(metrics.SettingsReloads)
+	r.MustRegister(metrics.Weather)
+	r.MustRegister(metrics.WeatherFactor)
+	r.MustRegister(metrics.DemandFactor)
+	r.MustRegister(metrics.Staff)
+	r.MustRegister(metrics.Payroll)
+	r.MustRegister(metrics.TotalSpace)
+	r.MustRegister(metrics.LandPrice)
+	r.MustRegister(metrics.GuestJobs)
+	r.MustRegister(metrics.GuestsTurnedAway)
+	r.MustRegister(metrics.GuestsNotSpawned)
+	r.MustRegister(metrics.LivePoints)
+	r.MustRegister(metrics.LivePushes)
+	r.MustRegister(metrics.LiveBuffered)
+	r.MustRegister(metrics.LiveBackoff)
+	r.MustRegister(metrics.LivePushDuration)
+}
+
+// setWeatherMetrics publishes the weather in the park
+func setWeatherMetrics(weather httptypes.Weather) {
+	for _, condition := range weatherConditions {
+		metrics.Weather.WithLabelValues(condition).Set(btof(weather.Condition == condition))
+	}
+	metrics.WeatherFactor.Set(weatherArrivalFactor(weather))
+}
+
+// setSettingsMetrics publishes the settings the park runs with
+func setSettingsMetrics(settings httptypes.ParkSettings) {
+	metrics.EntranceFee.Set(settings.EntranceFee)
+	metrics.IsParkClosed.Set(btof(settings.Closed))
+}
+
+// setCalendarMetrics publishes what kind of day it is
+func setCalendarMetrics(day httptypes.CalendarDay) {
+	metrics.OpensAt.Set(float64(day.Opens.Hour()))
+	metrics.ClosesAt.Set(float64(day.Closes.Hour()))
+	metrics.DemandFactor.Set(day.Demand)
+}
+
+// setLoanMetrics publishes the state of the park's borrowing
+func setLoanMetrics(loans httptypes.Loans) {
+	metrics.Debt.Set(loans.Debt)
+	metrics.Bankrupt.Set(btof(loans.Bankrupt))
+}
+
+// setLandMetrics publishes the land the park owns
+func setLandMetrics(land httptypes.Land) {
+	metrics.TotalSpace.Set(land.TotalSpace)
+	metrics.LandPrice.Set(land.PricePerAcre)
+}
+
+// setGuestMetrics publishes how many guests are in each phase of their visit
+func setGuestMetrics(phases map[string]int) {
+	for phase, count := range phases {
+		metrics.GuestJobs.WithLabelValues(phase).Set(float64(count))
+	}
+}
+
+// setReputationMetrics publishes the park's reputation
+func setReputationMetrics(reputation Reputation) {
+	metrics.Rating.Set(reputation.Park.Score)
+	for instance, rating := range reputation.Attractions {
+		metrics.Attractions.WithLabelValues(rating.Attraction, instance).Set(rating.Score)
+	}
+}
+
+// setScenarioMetrics publishes the progress through the scenario
+func setScenarioMetrics(scenario httptypes.Scenario) {
+	for _, objective := range scenario.Objectives {
+		metrics.Objectives.WithLabelValues(scenario.Name, objective.Name).Set(objective.Progress)
+	}
+	for _, outcome := range []string{OutcomeInProgress, OutcomeWon, OutcomeLost} {
+		metrics.Outcome.WithLabelValues(scenario.Name, outcome).Set(btof(scenario.Outcome == outcome))
+	}
+}
+
+// setStaffMetrics publishes the staff on duty
+func setStaffMetrics(counts map[string]int, staff httptypes.Staff) {
+	for role, count := range counts {
+		metrics.Staff.WithLabelValues(role).Set(float64(count))
+	}
+	metrics.Payroll.Set(staff.Payroll)
 }
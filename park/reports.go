@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+// buildDayReport summarizes the ledger entries of a day. lastLedgerID is the last entry
+// included in the previous report, so a day without entries carries it forward.
+func buildDayReport(day int, start time.Time, end time.Time, lastLedgerID int, entries []httptypes.LedgerEntry, balance float64, rating float64) httptypes.DayReport {
+	report := httptypes.DayReport{
+		Day:            day,
+		Start:          start,
+		End:            end,
+		Revenue:        make(map[httptypes.TransactionCategory]float64),
+		Costs:          make(map[httptypes.TransactionCategory]float64),
+		Attractions:    make(map[string]httptypes.AttractionDay),
+		ClosingBalance: balance,
+		Rating:         rating,
+		LastLedgerID:   lastLedgerID,
+	}
+
+	for _, entry := range entries {
+		report.LastLedgerID = max(report.LastLedgerID, entry.ID)
+	}
+
+	for _, entry := range entries {
+		net := entry.Net()
+
+		// Borrowing and paying back loans is neither revenue nor cost
+		if entry.Category == httptypes.CategoryLoan || entry.Category == httptypes.CategoryLoanRepayment {
+			report.Financing += net
+			continue
+		}
+
+		if net >= 0 {
+			report.Revenue[entry.Category] += net
+			report.TotalRevenue += net
+		} else {
+			report.Costs[entry.Category] += -net
+			report.TotalCosts += -net
+		}
+
+		if entry.Category == httptypes.CategoryEntranceFee {
+			report.Guests++
+		}
+
+		if entry.Instance == "" {
+			continue
+		}
+
+		attraction := report.Attractions[entry.Instance]
+		attraction.Attraction = entry.Attraction
+		if entry.Category == httptypes.CategoryRideFee {
+			attraction.Rides++
+		}
+		if net >= 0 {
+			attraction.Revenue += net
+		} else {
+			attraction.Costs += -net
+		}
+		report.Attractions[entry.Instance] = attraction
+	}
+
+	report.NetProfit = report.TotalRevenue - report.TotalCosts
+	return report
+}
+
+// writeReportsCSV writes one row per day report. Every category that shows up in any
+// report gets its own revenue and costs column.
+func writeReportsCSV(w io.Writer, reports []httptypes.DayReport) error {
+	seen := make(map[httptypes.TransactionCategory]bool)
+	for _, report := range reports {
+		for category := range report.Revenue {
+			seen[category] = true
+		}
+		for category := range report.Costs {
+			seen[category] = true
+		}
+	}
+
+	categories := make([]string, 0, len(seen))
+	for category := range seen {
+		categories = append(categories, string(category))
+	}
+	sort.Strings(categories)
+
+	header := []string{"day", "start", "end", "guests", "weather", "total_revenue", "total_costs", "net_profit", "financing", "closing_balance", "rating"}
+	for _, category := range categories {
+		header = append(header, "revenue_"+category, "costs_"+category)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		row := []string{
+			strconv.Itoa(report.Day),
+			report.Start.Format(time.RFC3339),
+			report.End.Format(time.RFC3339),
+			strconv.Itoa(report.Guests),
+			report.Weather,
+			formatMoney(report.TotalRevenue),
+			formatMoney(report.TotalCosts),
+			formatMoney(report.NetProfit),
+			formatMoney(report.Financing),
+			formatMoney(report.ClosingBalance),
+			fmt.Sprintf("%.2f", report.Rating),
+		}
+		for _, category := range categories {
+			row = append(row,
+				formatMoney(report.Revenue[httptypes.TransactionCategory(category)]),
+				formatMoney(report.Costs[httptypes.TransactionCategory(category)]))
+		}
+
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatMoney formats an amount of money with cents
+func formatMoney(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
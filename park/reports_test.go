@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+// entry builds a ledger entry the way the park records a signed amount
+func entry(id int, category httptypes.TransactionCategory, instance string, amount float64) httptypes.LedgerEntry {
+	e := newLedgerEntry(category, "carousel", instance, amount)
+	e.ID = id
+	return e
+}
+
+func TestBuildDayReport(t *testing.T) {
+	start := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 6, 1, 21, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		lastLedgerID     int
+		entries          []httptypes.LedgerEntry
+		wantLastLedgerID int
+		wantRevenue      float64
+		wantCosts        float64
+		wantProfit       float64
+		wantFinancing    float64
+		wantGuests       int
+		wantRides        int
+	}{
+		{
+			name:             "first day without entries",
+			lastLedgerID:     0,
+			wantLastLedgerID: 0,
+		},
+		{
+			name:             "day without entries keeps the previous last entry",
+			lastLedgerID:     7,
+			wantLastLedgerID: 7,
+		},
+		{
+			name:         "revenue and costs",
+			lastLedgerID: 7,
+			entries: []httptypes.LedgerEntry{
+				entry(8, httptypes.CategoryEntranceFee, "", 10),
+				entry(9, httptypes.CategoryEntranceFee, "", 10),
+				entry(10, httptypes.CategoryRideFee, "carousel-1", 5),
+				entry(11, httptypes.CategoryUpkeep, "carousel-1", -3),
+				entry(12, httptypes.CategoryWages, "", -4),
+			},
+			wantLastLedgerID: 12,
+			wantRevenue:      25,
+			wantCosts:        7,
+			wantProfit:       18,
+			wantGuests:       2,
+			wantRides:        1,
+		},
+		{
+			name:         "loans are financing, interest is a cost",
+			lastLedgerID: 12,
+			entries: []httptypes.LedgerEntry{
+				entry(13, httptypes.CategoryLoan, "", 1000),
+				entry(14, httptypes.CategoryLoanRepayment, "", -400),
+				entry(15, httptypes.CategoryInterest, "", -2),
+			},
+			wantLastLedgerID: 15,
+			wantCosts:        2,
+			wantProfit:       -2,
+			wantFinancing:    600,
+		},
+		{
+			name:         "refunded ride",
+			lastLedgerID: 15,
+			entries: []httptypes.LedgerEntry{
+				entry(16, httptypes.CategoryRideFee, "carousel-1", 5),
+				entry(17, httptypes.CategoryRefund, "carousel-1", -5),
+			},
+			wantLastLedgerID: 17,
+			wantRevenue:      5,
+			wantCosts:        5,
+			wantRides:        1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := buildDayReport(3, start, end, tt.lastLedgerID, tt.entries, 100, 4)
+
+			if report.Day != 3 || !report.Start.Equal(start) || !report.End.Equal(end) || report.ClosingBalance != 100 || report.Rating != 4 {
+				t.Errorf("buildDayReport() = day %d from %s to %s, balance %v, rating %v", report.Day, report.Start, report.End, report.ClosingBalance, report.Rating)
+			}
+			if report.LastLedgerID != tt.wantLastLedgerID {
+				t.Errorf("LastLedgerID = %d, want %d", report.LastLedgerID, tt.wantLastLedgerID)
+			}
+			if report.TotalRevenue != tt.wantRevenue {
+				t.Errorf("TotalRevenue = %v, want %v", report.TotalRevenue, tt.wantRevenue)
+			}
+			if report.TotalCosts != tt.wantCosts {
+				t.Errorf("TotalCosts = %v, want %v", report.TotalCosts, tt.wantCosts)
+			}
+			if report.NetProfit != tt.wantProfit {
+				t.Errorf("NetProfit = %v, want %v", report.NetProfit, tt.wantProfit)
+			}
+			if report.Financing != tt.wantFinancing {
+				t.Errorf("Financing = %v, want %v", report.Financing, tt.wantFinancing)
+			}
+			if report.Guests != tt.wantGuests {
+				t.Errorf("Guests = %d, want %d", report.Guests, tt.wantGuests)
+			}
+			if rides := report.Attractions["carousel-1"].Rides; rides != tt.wantRides {
+				t.Errorf("carousel-1 rides = %d, want %d", rides, tt.wantRides)
+			}
+		})
+	}
+}
+
+func TestBuildDayReportAfterEmptyDay(t *testing.T) {
+	state, err := NewStateManager(&Config{}, &Scenario{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// closeDays reports on the ledger entries since the previous report
+	closeDay := func() httptypes.DayReport {
+		lastLedgerID := state.GetDayLedgerID()
+		start := state.GetDayStart()
+		report := buildDayReport(state.GetDay(), start, start.Add(24*time.Hour), lastLedgerID,
+			state.GetLedger(LedgerFilter{AfterID: lastLedgerID}), state.GetMoney(), 0)
+		if err := state.AddDayReport(report); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	days := []struct {
+		name        string
+		fee         float64 // Entrance fee taken during the day, none if 0
+		wantRevenue float64
+	}{
+		{"day with a guest", 10, 10},
+		{"empty day", 0, 0},
+		{"day after the empty day", 20, 20},
+	}
+
+	for _, day := range days {
+		if day.fee > 0 {
+			if _, err := state.Record(httptypes.CategoryEntranceFee, "", "", day.fee); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if report := closeDay(); report.TotalRevenue != day.wantRevenue {
+			t.Errorf("%s: TotalRevenue = %v, want %v", day.name, report.TotalRevenue, day.wantRevenue)
+		}
+	}
+}
+
+func TestWriteReportsCSV(t *testing.T) {
+	reports := []httptypes.DayReport{
+		buildDayReport(1, time.Time{}, time.Time{}, 0, []httptypes.LedgerEntry{
+			entry(1, httptypes.CategoryEntranceFee, "", 10),
+		}, 10, 3),
+		buildDayReport(2, time.Time{}, time.Time{}, 1, []httptypes.LedgerEntry{
+			entry(2, httptypes.CategoryUpkeep, "carousel-1", -2.5),
+		}, 7.5, 3),
+	}
+
+	var buf bytes.Buffer
+	if err := writeReportsCSV(&buf, reports); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want a header and 2 days", len(rows))
+	}
+
+	column := make(map[string]int)
+	for i, name := range rows[0] {
+		column[name] = i
+	}
+
+	tests := []struct {
+		row    int
+		column string
+		want   string
+	}{
+		{1, "day", "1"},
+		{1, "revenue_entrance_fee", "10.00"},
+		{1, "costs_upkeep", "0.00"},
+		{1, "closing_balance", "10.00"},
+		{2, "day", "2"},
+		{2, "costs_upkeep", "2.50"},
+		{2, "net_profit", "-2.50"},
+		{2, "rating", "3.00"},
+	}
+
+	for _, tt := range tests {
+		i, ok := column[tt.column]
+		if !ok {
+			t.Errorf("missing column %s", tt.column)
+			continue
+		}
+		if got := rows[tt.row][i]; got != tt.want {
+			t.Errorf("row %d %s = %s, want %s", tt.row, tt.column, got, tt.want)
+		}
+	}
+}
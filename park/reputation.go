@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+
+	"kubepark/pkg/httptypes"
+)
+
+// minRatingWeight is the smallest weight a new report has on a rating,
+// so ratings keep following recent experiences
+const minRatingWeight = 0.05
+
+// Rating is a score from 0 to 5 stars built up from reports
+type Rating struct {
+	Score   float64 `json:"score"`
+	Reports int     `json:"reports"`
+}
+
+// add folds a new score into the rating. Early reports are averaged, later ones
+// move the rating by a fixed share.
+func (r Rating) add(score float64) Rating {
+	r.Reports++
+	weight := math.Max(1/float64(r.Reports), minRatingWeight)
+	r.Score += weight * (clampStars(score) - r.Score)
+	return r
+}
+
+// AttractionRating is the rating of an attraction instance
+type AttractionRating struct {
+	Attraction string `json:"attraction"`
+	Rating
+}
+
+// Reputation is what guests think of the park and its attractions
+type Reputation struct {
+	Park        Rating                      `json:"park"`
+	Attractions map[string]AttractionRating `json:"attractions"` // Ratings by attraction instance
+}
+
+// copy returns a deep copy of the reputation
+func (r Reputation) copy() Reputation {
+	attractions := make(map[string]AttractionRating, len(r.Attractions))
+	for instance, rating := range r.Attractions {
+		attractions[instance] = rating
+	}
+	r.Attractions = attractions
+	return r
+}
+
+// apply folds a visit report into the reputation
+func (r *Reputation) apply(report httptypes.VisitReport) {
+	r.Park = r.Park.add(report.Satisfaction)
+
+	if r.Attractions == nil {
+		r.Attractions = make(map[string]AttractionRating)
+	}
+
+	for _, ride := range report.Rides {
+		if ride.Instance == "" {
+			continue
+		}
+
+		rating := r.Attractions[ride.Instance]
+		rating.Attraction = ride.Attraction
+		rating.Rating = rating.Rating.add(rideScore(ride))
+		r.Attractions[ride.Instance] = rating
+	}
+}
+
+// rideScore rates a single ride
+func rideScore(ride httptypes.RideReport) float64 {
+	if ride.Success {
+		return 5
+	}
+
+	switch ride.Reason {
+	case "attraction_closed":
+		return 2
+	case "attraction_dirty":
+		return 1
+	case "weather_closed":
+		return 2.5 // Guests blame the weather rather than the park
+	case "attraction_broken":
+		return 1
+	default:
+		return 1.5
+	}
+}
+
+// clampStars limits a score to between 0 and 5 stars
+func clampStars(score float64) float64 {
+	return math.Max(0, math.Min(score, 5))
+}
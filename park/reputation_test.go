@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"kubepark/pkg/httptypes"
+)
+
+func TestRatingAdd(t *testing.T) {
+	var rating Rating
+
+	// Early reports are averaged
+	for _, score := range []float64{5, 3, 4} {
+		rating = rating.add(score)
+	}
+	if rating.Reports != 3 || math.Abs(rating.Score-4) > 1e-9 {
+		t.Errorf("rating = %+v after 5, 3 and 4 stars, want 4 stars from 3 reports", rating)
+	}
+
+	// Scores out of range count as 0 or 5 stars
+	if got := (Rating{}).add(9).Score; got != 5 {
+		t.Errorf("add(9).Score = %v, want 5", got)
+	}
+
+	// Later reports still move the rating by a fixed share
+	rating = Rating{Score: 5, Reports: 1000}.add(0)
+	if want := 5 * (1 - minRatingWeight); math.Abs(rating.Score-want) > 1e-9 {
+		t.Errorf("add(0).Score after 1000 reports = %v, want %v", rating.Score, want)
+	}
+}
+
+func TestReputationApply(t *testing.T) {
+	var reputation Reputation
+	reputation.apply(httptypes.VisitReport{
+		Satisfaction: 4,
+		Rides: []httptypes.RideReport{
+			{Attraction: "carousel", Instance: "carousel-1", Success: true},
+			{Attraction: "carousel", Instance: "carousel-2", Success: false, Reason: "attraction_broken"},
+			{Attraction: "carousel", Success: true}, // Unknown instance
+		},
+	})
+	reputation.apply(httptypes.VisitReport{
+		Satisfaction: 2,
+		Rides: []httptypes.RideReport{
+			{Attraction: "carousel", Instance: "carousel-1", Success: false, Reason: "attraction_closed"},
+		},
+	})
+
+	if reputation.Park.Reports != 2 || reputation.Park.Score != 3 {
+		t.Errorf("Park = %+v, want 3 stars from 2 reports", reputation.Park)
+	}
+	if len(reputation.Attractions) != 2 {
+		t.Fatalf("Attractions = %v, want ratings of 2 instances", reputation.Attractions)
+	}
+	if got := reputation.Attractions["carousel-1"]; got.Attraction != "carousel" || got.Reports != 2 || got.Score != 3.5 {
+		t.Errorf("carousel-1 = %+v, want 3.5 stars from 2 rides", got)
+	}
+	if got := reputation.Attractions["carousel-2"]; got.Reports != 1 || got.Score != 1 {
+		t.Errorf("carousel-2 = %+v, want 1 star from a broken ride", got)
+	}
+
+	// A copy doesn't share the attraction ratings
+	copied := reputation.copy()
+	copied.apply(httptypes.VisitReport{Rides: []httptypes.RideReport{{Attraction: "restroom", Instance: "restroom-1", Success: true}}})
+	if _, ok := reputation.Attractions["restroom-1"]; ok {
+		t.Errorf("applying a report to a copy changed the original")
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"kubepark/pkg/constants"
+	"kubepark/pkg/httptypes"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// Scenario outcomes
+const (
+	OutcomeInProgress = "in_progress"
+	OutcomeWon        = "won"
+	OutcomeLost       = "lost"
+)
+
+// Objective metrics a scenario can set targets for
+var objectiveMetrics = []string{"money", "rating", "net_profit", "guests", "attractions"}
+
+// Scenario describes the starting conditions and goals of a game
+type Scenario struct {
+	Name               string         `json:"name"`
+	Description        string         `json:"description,omitempty"`
+	StartingMoney      float64        `json:"starting_money"`
+	TotalSpace         float64        `json:"total_space"`                   // Starting land in acres
+	AllowedAttractions []string       `json:"allowed_attractions,omitempty"` // Attraction types that may be built, all if empty
+	Guests             GuestParams    `json:"guests"`
+	Weather            WeatherParams  `json:"weather"`
+	Calendar           CalendarParams `json:"calendar"`
+	Staff              StaffParams    `json:"staff"`
+	Loans              LoanTerms      `json:"loans"`
+	Land               LandTerms      `json:"land"`
+	TimeLimitDays      int            `json:"time_limit_days,omitempty"` // Days to meet all objectives, unlimited if 0
+	Objectives         []Objective    `json:"objectives,omitempty"`
+}
+
+// GuestParams describe how guests behave in a scenario
+type GuestParams struct {
+	ArrivalModel   string  `json:"arrival_model"`   // flat or demand
+	FlatRate       float64 `json:"flat_rate"`       // Arrivals per simulated hour with the flat model
+	PeakRate       float64 `json:"peak_rate"`       // Arrivals per simulated hour at peak time with the demand model
+	PeakHour       float64 `json:"peak_hour"`       // Hour of the day most guests arrive
+	PeakWidth      float64 `json:"peak_width"`      // Spread of arrivals around the peak in hours
+	FeeSensitivity float64 `json:"fee_sensitivity"` // How strongly the entrance fee deters guests
+	Money          float64 `json:"money"`           // Money each guest brings
+	MaxGuests      int     `json:"max_guests"`      // Most guests in the park at once, unlimited if 0
+}
+
+// Objective is a target the park has to reach
+type Objective struct {
+	Name   string  `json:"name,omitempty"`
+	Metric string  `json:"metric"`           // money, rating, net_profit, guests or attractions
+	Target float64 `json:"target"`           // Value the metric has to reach
+	ByDay  int     `json:"by_day,omitempty"` // Last day to reach the target, the time limit if 0
+}
+
+// label returns the name of the objective, making one up if it has none
+func (o Objective) label() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	return fmt.Sprintf("%s_%g", o.Metric, o.Target)
+}
+
+// ScenarioState is the persistent progress through a scenario
+type ScenarioState struct {
+	Outcome  string         `json:"outcome"`
+	MetOnDay map[string]int `json:"met_on_day"` // Day each objective was met by objective name
+}
+
+// builtinScenarios are the classic game modes
+var builtinScenarios = map[string]Scenario{
+	"easy": {
+		Name:          "easy",
+		StartingMoney: 100000,
+		TotalSpace:    300,
+		Guests:        defaultGuestParams(12, 1.5),
+		Weather:       WeatherParams{Sunny: 0.6, Rain: 0.25, Heat: 0.1, Storm: 0.05},
+		Calendar:      defaultCalendarParams(),
+		Staff:         StaffParams{MechanicWage: 30, JanitorWage: 15},
+		Loans:         LoanTerms{CreditLimit: 200000, DailyRate: 0.005},
+		Land:          LandTerms{PricePerAcre: 200, PriceIncrease: 5, MaxSpace: 1000},
+	},
+	"medium": {
+		Name:          "medium",
+		StartingMoney: 100000,
+		TotalSpace:    100,
+		Guests:        defaultGuestParams(8, 2),
+		Weather:       WeatherParams{Sunny: 0.5, Rain: 0.3, Heat: 0.12, Storm: 0.08},
+		Calendar:      defaultCalendarParams(),
+		Staff:         StaffParams{MechanicWage: 40, JanitorWage: 20},
+		Loans:         LoanTerms{CreditLimit: 100000, DailyRate: 0.01},
+		Land:          LandTerms{PricePerAcre: 300, PriceIncrease: 10, MaxSpace: 500},
+	},
+	"hard": {
+		Name:          "hard",
+		StartingMoney: 100000,
+		TotalSpace:    10,
+		Guests:        defaultGuestParams(6, 3),
+		Weather:       WeatherParams{Sunny: 0.4, Rain: 0.3, Heat: 0.15, Storm: 0.15},
+		Calendar:      defaultCalendarParams(),
+		Staff:         StaffParams{MechanicWage: 60, JanitorWage: 30},
+		Loans:         LoanTerms{CreditLimit: 50000, DailyRate: 0.02},
+		Land:          LandTerms{PricePerAcre: 500, PriceIncrease: 50, MaxSpace: 100},
+	},
+}
+
+// defaultGuestParams returns the guest behavior of the classic game modes
+func defaultGuestParams(peakRate float64, feeSensitivity float64) GuestParams {
+	return GuestParams{
+		ArrivalModel:   "demand",
+		FlatRate:       3.6,
+		PeakRate:       peakRate,
+		PeakHour:       13,
+		PeakWidth:      3,
+		FeeSensitivity: feeSensitivity,
+		Money:          constants.GuestMoney,
+		MaxGuests:      50,
+	}
+}
+
+// LoadScenario returns the scenario of the game. A scenario file, e.g. mounted from a
+// ConfigMap, starts from the built-in scenario of the mode and overrides what it sets.
+func LoadScenario(mode string, path string) (*Scenario, error) {
+	builtin, ok := builtinScenarios[mode]
+	if !ok {
+		modes := strings.Join(slices.Sorted(maps.Keys(builtinScenarios)), ", ")
+		if mode == "" {
+			return nil, fmt.Errorf("mode not set on park, expected one of %s", modes)
+		}
+		return nil, fmt.Errorf("unknown mode %q, expected one of %s", mode, modes)
+	}
+	scenario := builtin
+	scenario.Calendar.Holidays = slices.Clone(builtin.Calendar.Holidays)
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			slog.Info("No scenario file found, playing built-in scenario", "path", path, "mode", mode)
+		case err != nil:
+			return nil, fmt.Errorf("failed to read scenario: %w", err)
+		default:
+			if err := yaml.UnmarshalStrict(data, &scenario); err != nil {
+				return nil, fmt.Errorf("failed to parse scenario: %w", err)
+			}
+		}
+	}
+
+	if err := scenario.validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %q: %w", scenario.Name, err)
+	}
+
+	return &scenario, nil
+}
+
+// validate checks that the scenario can be played
+func (s *Scenario) validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name must be set")
+	}
+	if errs := validation.IsValidLabelValue(s.Name); len(errs) > 0 {
+		return fmt.Errorf("name %q can't label guest jobs: %s", s.Name, strings.Join(errs, ", "))
+	}
+	if s.StartingMoney < 0 {
+		return fmt.Errorf("starting money must not be negative")
+	}
+	if s.TotalSpace <= 0 {
+		return fmt.Errorf("total space must be positive")
+	}
+	if s.Guests.Money <= 0 {
+		return fmt.Errorf("guest money must be positive")
+	}
+	if s.Guests.MaxGuests < 0 {
+		return fmt.Errorf("max guests must not be negative")
+	}
+	if _, err := NewArrivalModel(s.Guests); err != nil {
+		return err
+	}
+	if err := s.Weather.validate(); err != nil {
+		return err
+	}
+	if err := s.Calendar.validate(); err != nil {
+		return err
+	}
+	if err := s.Land.validate(s.TotalSpace); err != nil {
+		return err
+	}
+	if s.Staff.MechanicWage < 0 || s.Staff.JanitorWage < 0 {
+		return fmt.Errorf("staff wages must not be negative")
+	}
+	names := make(map[string]bool, len(s.Objectives))
+	for _, objective := range s.Objectives {
+		if !slices.Contains(objectiveMetrics, objective.Metric) {
+			return fmt.Errorf("unknown objective metric %q", objective.Metric)
+		}
+		if names[objective.label()] {
+			return fmt.Errorf("objective %s is set more than once", objective.label())
+		}
+		names[objective.label()] = true
+		if s.TimeLimitDays > 0 && objective.ByDay > s.TimeLimitDays {
+			return fmt.Errorf("objective %s is due after the time limit", objective.label())
+		}
+	}
+	return nil
+}
+
+// allows returns whether the attraction type may be built
+func (s *Scenario) allows(attraction string) bool {
+	return len(s.AllowedAttractions) == 0 || slices.Contains(s.AllowedAttractions, attraction)
+}
+
+// deadline returns the last day to meet the objective, 0 if there is none
+func (s *Scenario) deadline(objective Objective) int {
+	if objective.ByDay > 0 {
+		return objective.ByDay
+	}
+	return s.TimeLimitDays
+}
+
+// evaluate updates the progress through the scenario at the end of a day
+func (s *Scenario) evaluate(progress ScenarioState, day int, values map[string]float64, bankrupt bool) ScenarioState {
+	if progress.Outcome != "" && progress.Outcome != OutcomeInProgress {
+		return progress
+	}
+
+	metOnDay := maps.Clone(progress.MetOnDay)
+	if metOnDay == nil {
+		metOnDay = make(map[string]int)
+	}
+	progress.MetOnDay = metOnDay
+	progress.Outcome = OutcomeInProgress
+
+	met := 0
+	missed := false
+	for _, objective := range s.Objectives {
+		if _, ok := metOnDay[objective.label()]; ok {
+			met++
+			continue
+		}
+
+		if values[objective.Metric] >= objective.Target {
+			metOnDay[objective.label()] = day
+			met++
+			continue
+		}
+
+		if deadline := s.deadline(objective); deadline > 0 && day >= deadline {
+			missed = true
+		}
+	}
+
+	switch {
+	case bankrupt || missed:
+		progress.Outcome = OutcomeLost
+	case len(s.Objectives) > 0 && met == len(s.Objectives):
+		progress.Outcome = OutcomeWon
+	case s.TimeLimitDays > 0 && day >= s.TimeLimitDays:
+		progress.Outcome = OutcomeLost
+	}
+
+	return progress
+}
+
+// status reports the progress through the scenario
+func (s *Scenario) status(progress ScenarioState, day int, values map[string]float64) httptypes.Scenario {
+	status := httptypes.Scenario{
+		Name:               s.Name,
+		Description:        s.Description,
+		Day:                day,
+		TimeLimitDays:      s.TimeLimitDays,
+		AllowedAttractions: s.AllowedAttractions,
+		Outcome:            progress.Outcome,
+	}
+	if status.Outcome == "" {
+		status.Outcome = OutcomeInProgress
+	}
+
+	for _, objective := range s.Objectives {
+		value := values[objective.Metric]
+		metOnDay, met := progress.MetOnDay[objective.label()]
+
+		progress := 1.0
+		if !met && objective.Target > 0 {
+			progress = max(0, min(value/objective.Target, 1))
+		}
+
+		status.Objectives = append(status.Objectives, httptypes.Objective{
+			Name:     objective.label(),
+			Metric:   objective.Metric,
+			Target:   objective.Target,
+			ByDay:    s.deadline(objective),
+			Value:    value,
+			Progress: progress,
+			Met:      met,
+			MetOnDay: metOnDay,
+		})
+	}
+
+	return status
+}
+
+// objectiveValues returns the current value of every objective metric
+func objectiveValues(state *StateManager, attractions *AttractionDirectory) map[string]float64 {
+	values := map[string]float64{
+		"money":       state.GetMoney(),
+		"rating":      state.GetReputation().Park.Score,
+		"attractions": float64(len(attractions.List())),
+	}
+
+	for _, report := range state.GetReports() {
+		values["net_profit"] += report.NetProfit
+		values["guests"] += float64(report.Guests)
+	}
+
+	return values
+}
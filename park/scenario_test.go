@@ -0,0 +1,196 @@
+package main
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestScenarioEvaluate(t *testing.T) {
+	scenario := &Scenario{
+		TimeLimitDays: 10,
+		Objectives: []Objective{
+			{Metric: "money", Target: 1000},
+			{Metric: "guests", Target: 100, ByDay: 5},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		progress     ScenarioState
+		day          int
+		values       map[string]float64
+		bankrupt     bool
+		wantOutcome  string
+		wantMetOnDay map[string]int
+	}{
+		{
+			name:         "nothing met yet",
+			day:          1,
+			values:       map[string]float64{"money": 500, "guests": 10},
+			wantOutcome:  OutcomeInProgress,
+			wantMetOnDay: map[string]int{},
+		},
+		{
+			name:         "one objective met",
+			day:          2,
+			values:       map[string]float64{"money": 1000, "guests": 10},
+			wantOutcome:  OutcomeInProgress,
+			wantMetOnDay: map[string]int{"money_1000": 2},
+		},
+		{
+			name:         "met objectives stay met",
+			progress:     ScenarioState{MetOnDay: map[string]int{"money_1000": 2}},
+			day:          3,
+			values:       map[string]float64{"money": 200, "guests": 10},
+			wantOutcome:  OutcomeInProgress,
+			wantMetOnDay: map[string]int{"money_1000": 2},
+		},
+		{
+			name:         "all objectives met",
+			progress:     ScenarioState{MetOnDay: map[string]int{"money_1000": 2}},
+			day:          4,
+			values:       map[string]float64{"money": 200, "guests": 150},
+			wantOutcome:  OutcomeWon,
+			wantMetOnDay: map[string]int{"money_1000": 2, "guests_100": 4},
+		},
+		{
+			name:         "objective met on its last day",
+			progress:     ScenarioState{MetOnDay: map[string]int{"money_1000": 2}},
+			day:          5,
+			values:       map[string]float64{"guests": 100},
+			wantOutcome:  OutcomeWon,
+			wantMetOnDay: map[string]int{"money_1000": 2, "guests_100": 5},
+		},
+		{
+			name:         "objective missed by its day",
+			day:          5,
+			values:       map[string]float64{"money": 2000, "guests": 99},
+			wantOutcome:  OutcomeLost,
+			wantMetOnDay: map[string]int{"money_1000": 5},
+		},
+		{
+			name:         "time limit reached",
+			progress:     ScenarioState{MetOnDay: map[string]int{"guests_100": 4}},
+			day:          10,
+			values:       map[string]float64{"money": 999},
+			wantOutcome:  OutcomeLost,
+			wantMetOnDay: map[string]int{"guests_100": 4},
+		},
+		{
+			name:         "bankrupt",
+			day:          1,
+			values:       map[string]float64{"money": -100},
+			bankrupt:     true,
+			wantOutcome:  OutcomeLost,
+			wantMetOnDay: map[string]int{},
+		},
+		{
+			name:         "game already over",
+			progress:     ScenarioState{Outcome: OutcomeLost, MetOnDay: map[string]int{}},
+			day:          6,
+			values:       map[string]float64{"money": 2000, "guests": 200},
+			wantOutcome:  OutcomeLost,
+			wantMetOnDay: map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := maps.Clone(tt.progress.MetOnDay)
+
+			got := scenario.evaluate(tt.progress, tt.day, tt.values, tt.bankrupt)
+			if got.Outcome != tt.wantOutcome {
+				t.Errorf("Outcome = %s, want %s", got.Outcome, tt.wantOutcome)
+			}
+			if !maps.Equal(got.MetOnDay, tt.wantMetOnDay) {
+				t.Errorf("MetOnDay = %v, want %v", got.MetOnDay, tt.wantMetOnDay)
+			}
+			if !maps.Equal(tt.progress.MetOnDay, before) {
+				t.Errorf("evaluate() changed the progress it was given to %v", tt.progress.MetOnDay)
+			}
+		})
+	}
+}
+
+func TestScenarioEvaluateKeepsProgressByName(t *testing.T) {
+	progress := ScenarioState{MetOnDay: map[string]int{"fortune": 3}}
+
+	// An objective was added in front of the one already met
+	scenario := &Scenario{
+		Objectives: []Objective{
+			{Name: "crowd", Metric: "guests", Target: 100},
+			{Name: "fortune", Metric: "money", Target: 1000},
+		},
+	}
+
+	got := scenario.evaluate(progress, 4, map[string]float64{"money": 0, "guests": 10}, false)
+	if want := map[string]int{"fortune": 3}; !maps.Equal(got.MetOnDay, want) {
+		t.Errorf("MetOnDay = %v, want %v", got.MetOnDay, want)
+	}
+	if got.Outcome != OutcomeInProgress {
+		t.Errorf("Outcome = %s, want %s", got.Outcome, OutcomeInProgress)
+	}
+}
+
+func TestScenarioValidateObjectiveNames(t *testing.T) {
+	tests := []struct {
+		name       string
+		objectives []Objective
+		wantErr    bool
+	}{
+		{"distinct names", []Objective{{Name: "a", Metric: "money", Target: 1}, {Name: "b", Metric: "money", Target: 2}}, false},
+		{"distinct unnamed", []Objective{{Metric: "money", Target: 1}, {Metric: "money", Target: 2}}, false},
+		{"same name", []Objective{{Name: "a", Metric: "money", Target: 1}, {Name: "a", Metric: "rating", Target: 2}}, true},
+		{"same unnamed", []Objective{{Metric: "money", Target: 1}, {Metric: "money", Target: 1, ByDay: 3}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scenario := builtinScenarios["easy"]
+			scenario.Objectives = tt.objectives
+			if err := scenario.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScenarioEvaluateWithoutObjectives(t *testing.T) {
+	tests := []struct {
+		name        string
+		scenario    Scenario
+		day         int
+		wantOutcome string
+	}{
+		{"sandbox keeps going", Scenario{}, 1000, OutcomeInProgress},
+		{"time limit reached", Scenario{TimeLimitDays: 7}, 7, OutcomeLost},
+		{"before the time limit", Scenario{TimeLimitDays: 7}, 6, OutcomeInProgress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.scenario.evaluate(ScenarioState{}, tt.day, nil, false)
+			if got.Outcome != tt.wantOutcome {
+				t.Errorf("Outcome = %s, want %s", got.Outcome, tt.wantOutcome)
+			}
+		})
+	}
+}
+
+func TestBuiltinScenariosAreValid(t *testing.T) {
+	for mode := range builtinScenarios {
+		t.Run(mode, func(t *testing.T) {
+			if _, err := LoadScenario(mode, ""); err != nil {
+				t.Errorf("LoadScenario() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadScenarioUnknownMode(t *testing.T) {
+	_, err := LoadScenario("expert", "")
+	if err == nil || !strings.Contains(err.Error(), `unknown mode "expert"`) || !strings.Contains(err.Error(), "easy, hard, medium") {
+		t.Errorf("LoadScenario(expert) error = %v, want the unknown mode and the built-in ones", err)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/settings"
+)
+
+// scheduleDays are the days the schedule can set opening hours for
+var scheduleDays = []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", holidaySchedule}
+
+// flagSettings returns the settings given on the command line, or their defaults
+func flagSettings(config *Config) httptypes.ParkSettings {
+	return httptypes.ParkSettings{
+		EntranceFee: config.EntranceFee,
+		OpensAt:     config.OpensAt,
+		ClosesAt:    config.ClosesAt,
+		Closed:      config.Closed,
+	}
+}
+
+// validateSettings checks that the park can run with the settings
+func validateSettings(s httptypes.ParkSettings) error {
+	if s.EntranceFee < 0 {
+		return fmt.Errorf("entrance_fee must not be negative")
+	}
+	if err := validateHours(httptypes.Hours{OpensAt: s.OpensAt, ClosesAt: s.ClosesAt}); err != nil {
+		return err
+	}
+	for day, hours := range s.Schedule {
+		if !slices.Contains(scheduleDays, day) {
+			return fmt.Errorf("schedule has unknown day %q, use a weekday or %s", day, holidaySchedule)
+		}
+		if err := validateHours(hours); err != nil {
+			return fmt.Errorf("schedule of %s: %w", day, err)
+		}
+	}
+	return nil
+}
+
+// validateHours checks that the opening hours are hours of the day. Closing at an
+// earlier hour than opening keeps the park open past midnight.
+func validateHours(hours httptypes.Hours) error {
+	if hours.OpensAt < 0 || hours.OpensAt > 23 {
+		return fmt.Errorf("opens_at must be an hour between 0 and 23")
+	}
+	if hours.ClosesAt < 0 || hours.ClosesAt > 24 {
+		return fmt.Errorf("closes_at must be an hour between 0 and 24")
+	}
+	if hours.OpensAt == hours.ClosesAt {
+		return fmt.Errorf("opens_at and closes_at must differ")
+	}
+	return nil
+}
+
+// newSettingsApplier returns what applies the park's settings file on top of its flags
+func newSettingsApplier(config *Config, state *StateManager) *settings.Applier[httptypes.ParkSettings] {
+	return &settings.Applier[httptypes.ParkSettings]{
+		Component: "park",
+		Flags: func() httptypes.ParkSettings {
+			return flagSettings(config)
+		},
+		Validate: validateSettings,
+		Store:    state.ApplySettings,
+		Applied:  setSettingsMetrics,
+		Reloads:  metrics.SettingsReloads,
+	}
+}
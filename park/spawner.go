@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Ways of spawning guests
+const (
+	GuestModeJobs  = "jobs"  // One Kubernetes job per guest
+	GuestModeCrowd = "crowd" // Guests of a crowd simulated in a single deployment
+)
+
+// Phases of a guest's visit
+const (
+	PhasePending   = "pending"
+	PhaseRunning   = "running"
+	PhaseSucceeded = "succeeded"
+	PhaseFailed    = "failed"
+)
+
+// GuestPhases lists every phase a guest goes through
+var GuestPhases = []string{PhasePending, PhaseRunning, PhaseSucceeded, PhaseFailed}
+
+// errParkFull is returned when as many guests are in the park as the scenario allows
+var errParkFull = errors.New("park is full")
+
+// GuestVisit describes the visit a guest is spawned for
+type GuestVisit struct {
+	Scenario string
+	Day      int
+	Arrival  time.Time // Simulated time the guest arrives
+	Money    float64   // Money the guest brings
+}
+
+// GuestSpawner sends arriving guests into the park
+type GuestSpawner interface {
+	// Start keeps track of the guests spawned until the context is done
+	Start(ctx context.Context) error
+
+	// Spawn sends a guest into the park, or returns errParkFull if there's no room
+	Spawn(ctx context.Context, visit GuestVisit) error
+
+	// SendHome asks every guest still in the park to leave, and returns how many were asked
+	SendHome(ctx context.Context) (int, error)
+
+	// Phases counts the guests in every phase of their visit
+	Phases() map[string]int
+}
+
+// NewGuestSpawner creates the guest spawner of the configured guest mode
+func NewGuestSpawner(config *Config, clientset kubernetes.Interface, maxGuests int) (GuestSpawner, error) {
+	switch config.GuestMode {
+	case GuestModeJobs:
+		parkURL := config.SelfURL
+		if parkURL == "" {
+			parkURL = "http://park:80"
+		}
+		return NewGuestJobManager(clientset, config.Image, parkURL, config.LogFormat, maxGuests)
+	case GuestModeCrowd:
+		return NewGuestCrowd(config.CrowdURL, maxGuests), nil
+	default:
+		return nil, fmt.Errorf("unknown guest mode %q, expected %s or %s", config.GuestMode, GuestModeJobs, GuestModeCrowd)
+	}
+}
+
+// newPhases returns a count of zero guests in every phase
+func newPhases() map[string]int {
+	phases := make(map[string]int, len(GuestPhases))
+	for _, phase := range GuestPhases {
+		phases[phase] = 0
+	}
+	return phases
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+// staffTimeout is how long staff may go without a heartbeat before they are considered off duty
+const staffTimeout = time.Minute
+
+// StaffParams describe what staff cost in a scenario
+type StaffParams struct {
+	MechanicWage float64 `json:"mechanic_wage"` // Wage of a mechanic per simulated hour
+	JanitorWage  float64 `json:"janitor_wage"`  // Wage of a janitor per simulated hour
+}
+
+// wage returns the wage of the role per simulated hour, and whether the role exists
+func (p StaffParams) wage(role string) (float64, bool) {
+	switch role {
+	case httptypes.RoleMechanic:
+		return p.MechanicWage, true
+	case httptypes.RoleJanitor:
+		return p.JanitorWage, true
+	default:
+		return 0, false
+	}
+}
+
+// staffMember is a member of staff on duty
+type staffMember struct {
+	role     string
+	lastSeen time.Time
+}
+
+// Payroll is the persistent state of the wages staff earned
+type Payroll struct {
+	Owed   float64   `json:"owed"`   // Wages earned since the last payday
+	Payday time.Time `json:"payday"` // Simulated time wages are paid next
+}
+
+// StaffRegistry tracks the staff on duty
+type StaffRegistry struct {
+	mu      sync.Mutex
+	members map[string]staffMember // Staff by name
+}
+
+// NewStaffRegistry creates a new staff registry
+func NewStaffRegistry() *StaffRegistry {
+	return &StaffRegistry{
+		members: make(map[string]staffMember),
+	}
+}
+
+// Heartbeat puts a member of staff on duty, or keeps them on it
+func (s *StaffRegistry) Heartbeat(name string, role string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.members[name] = staffMember{role: role, lastSeen: time.Now()}
+}
+
+// Expire takes staff off duty that stopped sending heartbeats
+func (s *StaffRegistry) Expire() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := 0
+	for name, member := range s.members {
+		if time.Since(member.lastSeen) > staffTimeout {
+			delete(s.members, name)
+			expired++
+		}
+	}
+	return expired
+}
+
+// Accrue returns the wages staff on duty earned during the elapsed simulated time
+func (s *StaffRegistry) Accrue(elapsed time.Duration, params StaffParams) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	earned := 0.0
+	for _, member := range s.members {
+		wage, _ := params.wage(member.role)
+		earned += wage * elapsed.Hours()
+	}
+	return earned
+}
+
+// payday adds the wages staff earned to what the park owes them, and pays what it owes once
+// every simulated hour. It must only be called while updating the state.
+func (state *ParkState) payday(now time.Time, earned float64) (httptypes.LedgerEntry, bool) {
+	state.Payroll.Owed += earned
+	if now.Before(state.Payroll.Payday) {
+		return httptypes.LedgerEntry{}, false
+	}
+
+	owed := state.Payroll.Owed
+	state.Payroll.Owed = 0
+	state.Payroll.Payday = now.Truncate(time.Hour).Add(time.Hour)
+	if owed <= 0 {
+		return httptypes.LedgerEntry{}, false
+	}
+
+	return state.record(httptypes.CategoryWages, "", "", -owed), true
+}
+
+// List returns the staff on duty and what they cost
+func (s *StaffRegistry) List(params StaffParams) httptypes.Staff {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	staff := httptypes.Staff{
+		Members: []httptypes.StaffMember{},
+	}
+	for name, member := range s.members {
+		wage, _ := params.wage(member.role)
+		staff.Members = append(staff.Members, httptypes.StaffMember{
+			Name:     name,
+			Role:     member.role,
+			Wage:     wage,
+			LastSeen: member.lastSeen,
+		})
+		staff.Payroll += wage
+	}
+
+	sort.Slice(staff.Members, func(i, j int) bool {
+		return staff.Members[i].Name < staff.Members[j].Name
+	})
+	return staff
+}
+
+// Count returns the number of staff on duty by role
+func (s *StaffRegistry) Count() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := map[string]int{
+		httptypes.RoleMechanic: 0,
+		httptypes.RoleJanitor:  0,
+	}
+	for _, member := range s.members {
+		counts[member.role]++
+	}
+	return counts
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+func TestStaffRegistryAccrue(t *testing.T) {
+	params := StaffParams{MechanicWage: 40, JanitorWage: 20}
+
+	tests := []struct {
+		name    string
+		roles   []string
+		elapsed time.Duration
+		want    float64
+	}{
+		{"nobody on duty", nil, time.Hour, 0},
+		{"one mechanic for an hour", []string{httptypes.RoleMechanic}, time.Hour, 40},
+		{"mechanic and janitor for half an hour", []string{httptypes.RoleMechanic, httptypes.RoleJanitor}, 30 * time.Minute, 30},
+		{"unknown roles earn nothing", []string{"juggler"}, time.Hour, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			staff := NewStaffRegistry()
+			for i, role := range tt.roles {
+				staff.Heartbeat(string(rune('a'+i)), role)
+			}
+
+			if got := staff.Accrue(tt.elapsed, params); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Accrue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaffRegistryExpire(t *testing.T) {
+	staff := NewStaffRegistry()
+	staff.Heartbeat("mechanic-1", httptypes.RoleMechanic)
+	staff.Heartbeat("janitor-1", httptypes.RoleJanitor)
+
+	// The janitor stopped sending heartbeats
+	staff.members["janitor-1"] = staffMember{role: httptypes.RoleJanitor, lastSeen: time.Now().Add(-2 * staffTimeout)}
+
+	if got := staff.Expire(); got != 1 {
+		t.Errorf("Expire() = %d, want 1", got)
+	}
+	counts := staff.Count()
+	if counts[httptypes.RoleMechanic] != 1 || counts[httptypes.RoleJanitor] != 0 {
+		t.Errorf("Count() = %v after expiring the janitor", counts)
+	}
+
+	// A heartbeat puts a member of staff back on duty
+	staff.Heartbeat("janitor-1", httptypes.RoleJanitor)
+	if got := staff.Expire(); got != 0 {
+		t.Errorf("Expire() = %d after a heartbeat, want 0", got)
+	}
+}
+
+func TestPayday(t *testing.T) {
+	start := time.Date(2025, 6, 1, 9, 15, 0, 0, time.UTC)
+	state := ParkState{Money: 1000}
+
+	// The first tick pays what was earned and sets the next payday
+	entry, paid := state.payday(start, 10)
+	if !paid || entry.Category != httptypes.CategoryWages || entry.Net() != -10 || state.Money != 990 {
+		t.Fatalf("payday() = %+v, %v with money %v, want wages of 10 paid", entry, paid, state.Money)
+	}
+	if want := start.Truncate(time.Hour).Add(time.Hour); !state.Payroll.Payday.Equal(want) {
+		t.Errorf("Payday = %v, want %v", state.Payroll.Payday, want)
+	}
+
+	// Wages add up until the next payday
+	for i := range 3 {
+		if _, paid := state.payday(start.Add(time.Duration(i+1)*10*time.Minute), 5); paid {
+			t.Fatalf("payday() paid before the next payday")
+		}
+	}
+	if state.Payroll.Owed != 15 {
+		t.Errorf("Owed = %v, want 15", state.Payroll.Owed)
+	}
+
+	entry, paid = state.payday(start.Add(45*time.Minute), 5)
+	if !paid || entry.Net() != -20 || state.Payroll.Owed != 0 || state.Money != 970 {
+		t.Errorf("payday() = %+v, %v with owed %v and money %v, want wages of 20 paid", entry, paid, state.Payroll.Owed, state.Money)
+	}
+
+	// Nothing is recorded when no wages are owed
+	if _, paid := state.payday(start.Add(2*time.Hour), 0); paid {
+		t.Errorf("payday() paid without wages owed")
+	}
+}
@@ -2,17 +2,54 @@ package main
 
 import (
 	"fmt"
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/settings"
 	"kubepark/pkg/state"
+	"maps"
+	"math/rand/v2"
 	"time"
 )
 
+// maxAuditEntries is the number of settings changes kept in the park state
+const maxAuditEntries = 500
+
 // ParkState represents the persistent state of the park
 type ParkState struct {
-	Money       float64   `json:"money"`
-	CurrentTime time.Time `json:"current_time"`
-	Mode        string    `json:"mode"`
-	EntranceFee float64   `json:"entrance_fee"`
-	TotalSpace  float64   `json:"total_space"` // Total park space in acres
+	Money       float64    `json:"money"`
+	CurrentTime time.Time  `json:"current_time"`
+	Mode        string     `json:"mode"`
+	TotalSpace  float64    `json:"total_space"` // Total park space in acres
+	Clock       ClockState `json:"clock"`
+
+	Settings       httptypes.ParkSettings `json:"settings"`        // Settings applied last
+	SettingsSource string                 `json:"settings_source"` // Where the applied settings came from
+	Audit          []httptypes.AuditEntry `json:"audit"`           // Changes to the settings
+
+	Ledger       []httptypes.LedgerEntry `json:"ledger"`
+	NextLedgerID int                     `json:"next_ledger_id"`
+
+	Credentials map[string]Credential `json:"credentials"` // Signing credentials by attraction instance
+
+	Reputation Reputation `json:"reputation"`
+
+	DayStart time.Time             `json:"day_start"` // Simulated time the current day started
+	Reports  []httptypes.DayReport `json:"reports"`   // Reports of all closed days
+
+	Loans      []httptypes.Loan `json:"loans"`
+	NextLoanID int              `json:"next_loan_id"`
+	Bankrupt   bool             `json:"bankrupt"` // Whether the game is over
+
+	Scenario ScenarioState `json:"scenario"`
+
+	Payroll Payroll `json:"payroll"` // Wages owed to staff
+
+	WeatherSeed uint64 `json:"weather_seed"` // Seed of the weather forecast unless the scenario sets one
+}
+
+// ClockState represents the persistent state of the simulation clock
+type ClockState struct {
+	Paused bool    `json:"paused"`
+	Speed  float64 `json:"speed"` // Multiplier on top of the base time scale
 }
 
 // StateManager manages the attraction's persistent state
@@ -21,24 +58,20 @@ type StateManager struct {
 }
 
 // NewStateManager creates a new state manager
-func NewStateManager(config *Config) (*StateManager, error) {
+func NewStateManager(config *Config, scenario *Scenario) (*StateManager, error) {
+	now := time.Now()
 	initialState := &ParkState{
-		Money:       100000, // Start with $100,000
-		CurrentTime: time.Now(),
-		Mode:        config.Mode,
-		EntranceFee: config.EntranceFee,
-	}
-
-	// Set total space based on mode
-	switch initialState.Mode {
-	case "easy":
-		initialState.TotalSpace = 300
-	case "medium":
-		initialState.TotalSpace = 100
-	case "hard":
-		initialState.TotalSpace = 10
-	default:
-		return nil, fmt.Errorf("mode not set on park")
+		Money:       scenario.StartingMoney,
+		CurrentTime: now,
+		DayStart:    now,
+		Mode:        scenario.Name,
+		TotalSpace:  scenario.TotalSpace,
+		Clock: ClockState{
+			Speed: 1,
+		},
+		Reputation: Reputation{
+			Park: Rating{Score: neutralReputation},
+		},
 	}
 
 	manager, err := state.New(initialState, config.VolumePath)
@@ -51,25 +84,116 @@ func NewStateManager(config *Config) (*StateManager, error) {
 	}, nil
 }
 
+// Flush saves the state to disk, e.g. before shutting down
+func (s *StateManager) Flush() error {
+	return s.manager.Flush()
+}
+
 func (s *StateManager) set(setter func(*ParkState)) error {
-	state := s.manager.Get().(*ParkState)
-	setter(state)
-	return s.manager.Set(state)
+	return s.manager.Update(func(state interface{}) {
+		setter(state.(*ParkState))
+	})
 }
 
-func (s *StateManager) get() *ParkState {
-	return s.manager.Get().(*ParkState)
+func (s *StateManager) view(viewer func(*ParkState)) {
+	s.manager.View(func(state interface{}) {
+		viewer(state.(*ParkState))
+	})
 }
 
+// touch applies a frequent change that is cheap to lose, which is saved with the next change or flush
+func (s *StateManager) touch(setter func(*ParkState)) error {
+	return s.manager.Modify(func(state interface{}) {
+		setter(state.(*ParkState))
+	})
+}
+
+// GetEntranceFee returns the fee guests pay to enter the park
 func (s *StateManager) GetEntranceFee() float64 {
-	return s.get().EntranceFee
+	return s.GetSettings().EntranceFee
 }
 
-// AddCash adds to the park's cash amount
-func (s *StateManager) AddMoney(amount float64) error {
-	return s.set(func(state *ParkState) {
-		state.Money += amount
+// GetSettings returns the settings the park runs with
+func (s *StateManager) GetSettings() (current httptypes.ParkSettings) {
+	s.view(func(state *ParkState) {
+		current = state.Settings
 	})
+	return current
+}
+
+// GetSettingsStatus returns the settings the park runs with and how they changed
+func (s *StateManager) GetSettingsStatus() (status httptypes.ParkSettingsResponse) {
+	s.view(func(state *ParkState) {
+		status = httptypes.ParkSettingsResponse{
+			Settings: state.Settings,
+			Source:   state.SettingsSource,
+			Audit:    append([]httptypes.AuditEntry{}, state.Audit...),
+		}
+	})
+	return status
+}
+
+// ApplySettings replaces the park's settings and records what changed in the audit log
+func (s *StateManager) ApplySettings(configured httptypes.ParkSettings, source string) (changes []httptypes.AuditEntry, err error) {
+	err = s.set(func(state *ParkState) {
+		changes = settings.Diff(source, state.CurrentTime, state.Settings, configured)
+		state.Settings = configured
+		state.SettingsSource = source
+
+		state.Audit = settings.Audit(state.Audit, changes, maxAuditEntries)
+	})
+	return changes, err
+}
+
+// Record adds a transaction to the ledger and applies it to the park's money
+func (s *StateManager) Record(category httptypes.TransactionCategory, attraction string, instance string, amount float64) (httptypes.LedgerEntry, error) {
+	var entry httptypes.LedgerEntry
+	err := s.set(func(state *ParkState) {
+		entry = state.record(category, attraction, instance, amount)
+	})
+	return entry, err
+}
+
+// record adds a transaction to the ledger and applies it to the park's money.
+// It must only be called while updating the state.
+func (state *ParkState) record(category httptypes.TransactionCategory, attraction string, instance string, amount float64) httptypes.LedgerEntry {
+	state.Money += amount
+	state.NextLedgerID++
+
+	entry := newLedgerEntry(category, attraction, instance, amount)
+	entry.ID = state.NextLedgerID
+	entry.Time = state.CurrentTime
+	entry.Balance = state.Money
+
+	state.Ledger = append(state.Ledger, entry)
+
+	// Entries the current day's report hasn't included yet are kept until it has, however many
+	reported := state.dayLedgerID()
+	trim := 0
+	for trim < len(state.Ledger)-maxLedgerEntries && state.Ledger[trim].ID <= reported {
+		trim++
+	}
+	state.Ledger = state.Ledger[trim:]
+
+	return entry
+}
+
+// GetLedger returns the ledger entries selected by the filter, most recent last
+func (s *StateManager) GetLedger(filter LedgerFilter) []httptypes.LedgerEntry {
+	entries := []httptypes.LedgerEntry{}
+	s.view(func(state *ParkState) {
+		for _, entry := range state.Ledger {
+			if filter.matches(entry) {
+				entries = append(entries, entry)
+			}
+		}
+	})
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+
+	return entries
 }
 
 // SetCash sets the park's cash amount
@@ -80,23 +204,273 @@ func (s *StateManager) SetMoney(amount float64) error {
 }
 
 // GetCash returns the park's current cash amount
-func (s *StateManager) GetMoney() float64 {
-	return s.get().Money
+func (s *StateManager) GetMoney() (money float64) {
+	s.view(func(state *ParkState) {
+		money = state.Money
+	})
+	return money
 }
 
 // GetTime returns the park's current time
-func (s *StateManager) GetTime() time.Time {
-	return s.get().CurrentTime
+func (s *StateManager) GetTime() (t time.Time) {
+	s.view(func(state *ParkState) {
+		t = state.CurrentTime
+	})
+	return t
 }
 
-// SetTime sets the park's current time
-func (s *StateManager) SetTime(t time.Time) error {
-	return s.set(func(state *ParkState) {
+// AdvanceTime moves the park's time forward by what step returns for the clock, unless the clock
+// is paused, and returns how much time passed. It changes every tick, so it is only saved now and then.
+func (s *StateManager) AdvanceTime(step func(clock ClockState) time.Duration) (elapsed time.Duration, err error) {
+	err = s.touch(func(state *ParkState) {
+		if state.Clock.Paused {
+			return
+		}
+		elapsed = step(state.Clock)
+		state.CurrentTime = state.CurrentTime.Add(elapsed)
+	})
+	return elapsed, err
+}
+
+// FastForwardTime jumps the park's time forward to t
+func (s *StateManager) FastForwardTime(t time.Time) (err error) {
+	setErr := s.set(func(state *ParkState) {
+		if !t.After(state.CurrentTime) {
+			err = fmt.Errorf("cannot fast-forward to a time in the past")
+			return
+		}
 		state.CurrentTime = t
 	})
+	if err != nil {
+		return err
+	}
+	return setErr
 }
 
 // GetTotalSpace returns the total space in the park
-func (s *StateManager) GetTotalSpace() float64 {
-	return s.get().TotalSpace
+func (s *StateManager) GetTotalSpace() (space float64) {
+	s.view(func(state *ParkState) {
+		space = state.TotalSpace
+	})
+	return space
+}
+
+// GetClock returns the state of the simulation clock
+func (s *StateManager) GetClock() (clock ClockState) {
+	s.view(func(state *ParkState) {
+		clock = state.Clock
+	})
+	return clock
+}
+
+// SetClockPaused sets whether the simulation clock is paused
+func (s *StateManager) SetClockPaused(paused bool) error {
+	return s.set(func(state *ParkState) {
+		state.Clock.Paused = paused
+	})
+}
+
+// SetClockSpeed sets the speed multiplier of the simulation clock
+func (s *StateManager) SetClockSpeed(speed float64) error {
+	return s.set(func(state *ParkState) {
+		state.Clock.Speed = speed
+	})
+}
+
+// GetCredential returns the signing credential of an attraction instance
+func (s *StateManager) GetCredential(instance string) (credential Credential, ok bool) {
+	s.view(func(state *ParkState) {
+		credential, ok = state.Credentials[instance]
+	})
+	return credential, ok
+}
+
+// SetCredential sets the signing credential of an attraction instance
+func (s *StateManager) SetCredential(instance string, credential Credential) error {
+	return s.set(func(state *ParkState) {
+		if state.Credentials == nil {
+			state.Credentials = make(map[string]Credential)
+		}
+		state.Credentials[instance] = credential
+	})
+}
+
+// GetReputation returns what guests think of the park and its attractions
+func (s *StateManager) GetReputation() (reputation Reputation) {
+	s.view(func(state *ParkState) {
+		reputation = state.Reputation.copy()
+	})
+	return reputation
+}
+
+// AddVisitReport folds a guest's visit report into the park's reputation
+func (s *StateManager) AddVisitReport(report httptypes.VisitReport) (Reputation, error) {
+	var reputation Reputation
+	err := s.set(func(state *ParkState) {
+		state.Reputation.apply(report)
+		reputation = state.Reputation.copy()
+	})
+	return reputation, err
+}
+
+// GetDayLedgerID returns the ID of the last ledger entry included in a day report
+func (s *StateManager) GetDayLedgerID() (id int) {
+	s.view(func(state *ParkState) {
+		id = state.dayLedgerID()
+	})
+	return id
+}
+
+// dayLedgerID returns the ID of the last ledger entry included in a day report.
+// It must only be called while viewing or updating the state.
+func (state *ParkState) dayLedgerID() int {
+	if len(state.Reports) == 0 {
+		return 0
+	}
+	return state.Reports[len(state.Reports)-1].LastLedgerID
+}
+
+// GetDayStart returns the simulated time the current day started
+func (s *StateManager) GetDayStart() (start time.Time) {
+	s.view(func(state *ParkState) {
+		start = state.DayStart
+	})
+	return start
+}
+
+// AddDayReport stores the report of a closed day and starts the next day
+func (s *StateManager) AddDayReport(report httptypes.DayReport) error {
+	return s.set(func(state *ParkState) {
+		state.Reports = append(state.Reports, report)
+		state.DayStart = report.End
+	})
+}
+
+// GetReports returns the reports of all closed days
+func (s *StateManager) GetReports() (reports []httptypes.DayReport) {
+	s.view(func(state *ParkState) {
+		reports = append([]httptypes.DayReport{}, state.Reports...)
+	})
+	return reports
+}
+
+// GetDay returns the number of the current day, starting at 1
+func (s *StateManager) GetDay() (day int) {
+	s.view(func(state *ParkState) {
+		day = len(state.Reports) + 1
+	})
+	return day
+}
+
+// TakeLoan borrows money from the bank
+func (s *StateManager) TakeLoan(amount float64, terms LoanTerms) (loan httptypes.Loan, entry httptypes.LedgerEntry, err error) {
+	setErr := s.set(func(state *ParkState) {
+		loan, entry, err = state.takeLoan(amount, terms)
+	})
+	if err != nil {
+		return loan, entry, err
+	}
+	return loan, entry, setErr
+}
+
+// RepayLoan pays back some or all of a loan, the whole balance if amount is 0
+func (s *StateManager) RepayLoan(id int, amount float64) (entry httptypes.LedgerEntry, err error) {
+	setErr := s.set(func(state *ParkState) {
+		entry, err = state.repayLoan(id, amount)
+	})
+	if err != nil {
+		return entry, err
+	}
+	return entry, setErr
+}
+
+// CloseDayFinances charges a day of interest on all loans and checks whether the park went bankrupt
+func (s *StateManager) CloseDayFinances(terms LoanTerms) (entries []httptypes.LedgerEntry, bankrupt bool, err error) {
+	err = s.set(func(state *ParkState) {
+		entries = state.chargeInterest()
+		bankrupt = state.checkBankruptcy(terms)
+	})
+	return entries, bankrupt, err
+}
+
+// GetLoans returns the state of the park's borrowing
+func (s *StateManager) GetLoans(terms LoanTerms) (loans httptypes.Loans) {
+	s.view(func(state *ParkState) {
+		debt := state.debt()
+		loans = httptypes.Loans{
+			Loans:       append([]httptypes.Loan{}, state.Loans...),
+			Debt:        debt,
+			CreditLimit: terms.CreditLimit,
+			Available:   max(0, terms.CreditLimit-debt),
+			Bankrupt:    state.Bankrupt,
+		}
+	})
+	return loans
+}
+
+// BuyLand buys acres of land next to the park
+func (s *StateManager) BuyLand(acres float64, terms LandTerms) (entry httptypes.LedgerEntry, err error) {
+	setErr := s.set(func(state *ParkState) {
+		entry, err = state.buyLand(acres, terms)
+	})
+	if err != nil {
+		return entry, err
+	}
+	return entry, setErr
+}
+
+// GetLand returns the land the park owns and what more costs
+func (s *StateManager) GetLand(terms LandTerms) (land httptypes.Land) {
+	s.view(func(state *ParkState) {
+		land = state.land(terms)
+	})
+	return land
+}
+
+// IsBankrupt returns whether the game is over
+func (s *StateManager) IsBankrupt() (bankrupt bool) {
+	s.view(func(state *ParkState) {
+		bankrupt = state.Bankrupt
+	})
+	return bankrupt
+}
+
+// GetScenarioState returns the progress through the scenario
+func (s *StateManager) GetScenarioState() (progress ScenarioState) {
+	s.view(func(state *ParkState) {
+		progress = state.Scenario
+		progress.MetOnDay = maps.Clone(state.Scenario.MetOnDay)
+	})
+	return progress
+}
+
+// SetScenarioState sets the progress through the scenario
+func (s *StateManager) SetScenarioState(progress ScenarioState) error {
+	return s.set(func(state *ParkState) {
+		state.Scenario = progress
+	})
+}
+
+// PayStaff adds the wages staff earned to what the park owes them, and pays what it owes once
+// every simulated hour. Wages add up every tick, so they are only saved now and then, but a
+// payday is saved right away.
+func (s *StateManager) PayStaff(now time.Time, earned float64) (entry httptypes.LedgerEntry, paid bool, err error) {
+	err = s.touch(func(state *ParkState) {
+		entry, paid = state.payday(now, earned)
+	})
+	if err == nil && paid {
+		err = s.Flush()
+	}
+	return entry, paid, err
+}
+
+// GetWeatherSeed returns the seed of the park's weather forecast, picking one the first time
+func (s *StateManager) GetWeatherSeed() (seed uint64, err error) {
+	err = s.set(func(state *ParkState) {
+		for state.WeatherSeed == 0 {
+			state.WeatherSeed = rand.Uint64()
+		}
+		seed = state.WeatherSeed
+	})
+	return seed, err
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+// forecastDays is the number of days the forecast looks ahead
+const forecastDays = 7
+
+// weatherConditions are all weather conditions, in the order chances are drawn
+var weatherConditions = []string{httptypes.WeatherSunny, httptypes.WeatherRain, httptypes.WeatherHeat, httptypes.WeatherStorm}
+
+// weatherArrivalFactors scale the guest arrival rate by weather condition
+var weatherArrivalFactors = map[string]float64{
+	httptypes.WeatherSunny: 1,
+	httptypes.WeatherHeat:  0.8,
+	httptypes.WeatherRain:  0.5,
+	httptypes.WeatherStorm: 0.2,
+}
+
+// WeatherParams describe the climate of a scenario as the relative chance of each
+// condition on any given day
+type WeatherParams struct {
+	Seed  uint64  `json:"seed,omitempty"` // Seed of the forecast, picked once per park if 0
+	Sunny float64 `json:"sunny"`
+	Rain  float64 `json:"rain"`
+	Heat  float64 `json:"heat"`
+	Storm float64 `json:"storm"`
+}
+
+// chances returns the relative chance of each weather condition
+func (p WeatherParams) chances() []float64 {
+	return []float64{p.Sunny, p.Rain, p.Heat, p.Storm}
+}
+
+// validate checks that some weather can be drawn
+func (p WeatherParams) validate() error {
+	total := 0.0
+	for _, chance := range p.chances() {
+		if chance < 0 {
+			return fmt.Errorf("weather chances must not be negative")
+		}
+		total += chance
+	}
+	if total == 0 {
+		return fmt.Errorf("at least one weather chance must be positive")
+	}
+	return nil
+}
+
+// WeatherGenerator forecasts the weather of each simulated day. The same seed always
+// gives the same weather on the same date, so the forecast survives restarts.
+type WeatherGenerator struct {
+	seed    uint64
+	chances []float64
+}
+
+// NewWeatherGenerator creates a weather generator for the climate
+func NewWeatherGenerator(params WeatherParams, seed uint64) *WeatherGenerator {
+	return &WeatherGenerator{
+		seed:    seed,
+		chances: params.chances(),
+	}
+}
+
+// On returns the weather on the date of t
+func (g *WeatherGenerator) On(t time.Time) httptypes.Weather {
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	rng := rand.New(rand.NewPCG(g.seed, uint64(date.Unix()/(24*60*60))))
+
+	total := 0.0
+	for _, chance := range g.chances {
+		total += chance
+	}
+
+	draw := rng.Float64() * total
+	condition := weatherConditions[0]
+	for i, chance := range g.chances {
+		if draw < chance {
+			condition = weatherConditions[i]
+			break
+		}
+		draw -= chance
+	}
+
+	return httptypes.Weather{
+		Date:      date.Format(time.DateOnly),
+		Condition: condition,
+	}
+}
+
+// Forecast returns the weather on the date of t and the days after it
+func (g *WeatherGenerator) Forecast(t time.Time) httptypes.Forecast {
+	forecast := httptypes.Forecast{
+		Today: g.On(t),
+	}
+	for day := 1; day <= forecastDays; day++ {
+		forecast.Days = append(forecast.Days, g.On(t.AddDate(0, 0, day)))
+	}
+	return forecast
+}
+
+// weatherArrivalFactor returns how much the weather scales the guest arrival rate
+func weatherArrivalFactor(weather httptypes.Weather) float64 {
+	if factor, ok := weatherArrivalFactors[weather.Condition]; ok {
+		return factor
+	}
+	return 1
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"kubepark/pkg/httptypes"
+)
+
+func TestWeatherGeneratorOn(t *testing.T) {
+	params := WeatherParams{Sunny: 0.5, Rain: 0.3, Heat: 0.1, Storm: 0.1}
+	weather := NewWeatherGenerator(params, 42)
+
+	morning := weather.On(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC))
+	evening := weather.On(time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC))
+	if morning != evening {
+		t.Errorf("On() = %v in the morning and %v in the evening, want the same weather all day", morning, evening)
+	}
+	if morning.Date != "2025-06-01" {
+		t.Errorf("On().Date = %q, want 2025-06-01", morning.Date)
+	}
+
+	// The same seed always forecasts the same weather
+	again := NewWeatherGenerator(params, 42)
+	for day := range 30 {
+		date := time.Date(2025, 6, 1+day, 12, 0, 0, 0, time.UTC)
+		if weather.On(date) != again.On(date) {
+			t.Fatalf("On(%v) differs between generators with the same seed", date)
+		}
+	}
+
+	// Only conditions with a chance are drawn
+	sunny := NewWeatherGenerator(WeatherParams{Sunny: 1}, 7)
+	for day := range 30 {
+		if got := sunny.On(time.Date(2025, 6, 1+day, 12, 0, 0, 0, time.UTC)); got.Condition != "sunny" {
+			t.Fatalf("On() = %q with only sunny weather", got.Condition)
+		}
+	}
+}
+
+func TestHandleWeatherFollowsCalendarDay(t *testing.T) {
+	scenario := builtinScenarios["easy"]
+	state, err := NewStateManager(&Config{}, &scenario)
+	if err != nil {
+		t.Fatalf("NewStateManager() error = %v", err)
+	}
+
+	// After midnight the park is still open from the day before
+	if _, err := state.ApplySettings(httptypes.ParkSettings{OpensAt: 18, ClosesAt: 2}, "test"); err != nil {
+		t.Fatalf("ApplySettings() error = %v", err)
+	}
+	if err := state.FastForwardTime(time.Date(2030, 6, 2, 1, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("FastForwardTime() error = %v", err)
+	}
+
+	weather := NewWeatherGenerator(scenario.Weather, 42)
+	calendar := NewCalendar(scenario.Calendar)
+
+	rec := httptest.NewRecorder()
+	handleWeather(state, weather, calendar)(rec, httptest.NewRequest(http.MethodGet, "/weather", nil))
+	var forecast httptypes.Forecast
+	if err := json.NewDecoder(rec.Body).Decode(&forecast); err != nil {
+		t.Fatalf("failed to decode forecast: %v", err)
+	}
+	if forecast.Today.Date != "2030-06-01" {
+		t.Errorf("forecast today = %s, want 2030-06-01", forecast.Today.Date)
+	}
+
+	// /park-status reports the weather of the same day
+	rec = httptest.NewRecorder()
+	handleStatus(state, NewGuestRegistry(), weather, calendar)(rec, httptest.NewRequest(http.MethodGet, "/park-status", nil))
+	var park httptypes.Park
+	if err := json.NewDecoder(rec.Body).Decode(&park); err != nil {
+		t.Fatalf("failed to decode park status: %v", err)
+	}
+	if park.Weather != forecast.Today.Condition {
+		t.Errorf("park status weather = %q, forecast today = %q", park.Weather, forecast.Today.Condition)
+	}
+}
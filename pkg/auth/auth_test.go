@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"amount":5,"category":"ride_fee"}`)
+
+	tests := []struct {
+		name    string
+		tamper  func(req *http.Request)
+		key     string
+		body    []byte
+		skew    time.Duration
+		wantErr bool
+	}{
+		{name: "signed request", key: key, body: body},
+		{name: "clock slightly ahead", key: key, body: body, skew: MaxSkew - time.Minute},
+		{name: "clock slightly behind", key: key, body: body, skew: -MaxSkew + time.Minute},
+		{name: "clock too far ahead", key: key, body: body, skew: MaxSkew + time.Minute, wantErr: true},
+		{name: "clock too far behind", key: key, body: body, skew: -MaxSkew - time.Minute, wantErr: true},
+		{name: "other key", key: otherKey, body: body, wantErr: true},
+		{name: "changed body", key: key, body: []byte(`{"amount":500,"category":"ride_fee"}`), wantErr: true},
+		{
+			name: "other instance", key: key, body: body, wantErr: true,
+			tamper: func(req *http.Request) { req.Header.Set(HeaderAttraction, "restroom-1") },
+		},
+		{
+			name: "other nonce", key: key, body: body, wantErr: true,
+			tamper: func(req *http.Request) { req.Header.Set(HeaderNonce, "0000") },
+		},
+		{
+			name: "other path", key: key, body: body, wantErr: true,
+			tamper: func(req *http.Request) { req.URL.Path = "/heartbeat" },
+		},
+		{
+			name: "invalid timestamp", key: key, body: body, wantErr: true,
+			tamper: func(req *http.Request) { req.Header.Set(HeaderTimestamp, "yesterday") },
+		},
+		{
+			name: "unsigned", key: key, body: body, wantErr: true,
+			tamper: func(req *http.Request) { req.Header.Del(HeaderSignature) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "http://park/transaction", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Sign(req, "carousel-1", key, body); err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(req)
+			}
+
+			err = Verify(req, tt.key, tt.body, time.Now().Add(tt.skew))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignUsesFreshNonces(t *testing.T) {
+	nonces := make(map[string]bool)
+	for range 10 {
+		req, err := http.NewRequest(http.MethodPost, "http://park/transaction", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Sign(req, "carousel-1", "key", nil); err != nil {
+			t.Fatal(err)
+		}
+
+		nonce := req.Header.Get(HeaderNonce)
+		if nonces[nonce] {
+			t.Fatalf("nonce %s used twice", nonce)
+		}
+		nonces[nonce] = true
+	}
+}
@@ -5,3 +5,9 @@ const (
 	// GuestSize is the amount of space each guest takes up in acres
 	GuestSize = 0.1
 )
+
+// Guest-related constants
+const (
+	// GuestMoney is the amount of money each guest brings to the park
+	GuestMoney = 100.0
+)
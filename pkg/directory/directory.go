@@ -0,0 +1,40 @@
+package directory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/tracing"
+)
+
+// client gives up on the park quickly, guests and staff will ask again soon enough
+var client = tracing.NewClient(2 * time.Second)
+
+// Attractions asks the park for the attractions registered with it
+func Attractions(ctx context.Context, parkURL string) ([]httptypes.Attraction, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parkURL+"/attractions", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attractions: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("attraction directory request failed with status: %d", resp.StatusCode)
+	}
+
+	var attractions []httptypes.Attraction
+	if err := json.NewDecoder(resp.Body).Decode(&attractions); err != nil {
+		return nil, fmt.Errorf("failed to decode attractions: %v", err)
+	}
+
+	return attractions, nil
+}
@@ -1,8 +1,34 @@
 package httptypes
 
+import "time"
+
 // Attraction is the info needed for guests to visit an attraction
 type Attraction struct {
-	URL  string  `json:"url"`
-	Fee  float64 `json:"fee"`
-	Size float64 `json:"size"` // Size in acres
+	Name     string  `json:"name"`     // Attraction type
+	Instance string  `json:"instance"` // Attraction instance
+	URL      string  `json:"url"`
+	Fee      float64 `json:"fee"`
+	Size     float64 `json:"size"` // Size in acres
+
+	Closed    bool    `json:"closed"`
+	Broken    bool    `json:"broken"`
+	Dirtiness float64 `json:"dirtiness"` // From 0 for spotless to 1 for too dirty to use
+
+	LastSeen time.Time `json:"last_seen,omitzero"` // When the park last heard from the attraction
+}
+
+// HeaderReason carries the reason an attraction refused a guest
+const HeaderReason = "X-Kubepark-Reason"
+
+// AttractionSettings are the attraction settings that can be changed while the attraction runs
+type AttractionSettings struct {
+	Fee    float64 `json:"fee"`
+	Closed bool    `json:"closed"`
+}
+
+// AttractionSettingsResponse is the response to an attraction settings request
+type AttractionSettingsResponse struct {
+	Settings AttractionSettings `json:"settings"`
+	Source   string             `json:"source"` // Where the current settings came from
+	Audit    []AuditEntry       `json:"audit"`
 }
@@ -0,0 +1,19 @@
+package httptypes
+
+// CrowdGuest asks the guest crowd to send one more guest into the park
+type CrowdGuest struct {
+	Money float64 `json:"money"` // Money the guest brings
+}
+
+// CrowdStatus is the response to a guest crowd status request
+type CrowdStatus struct {
+	Size     int `json:"size"`     // Most guests the crowd simulates at once
+	Active   int `json:"active"`   // Guests visiting the park right now
+	Finished int `json:"finished"` // Guests that finished their visit
+	Failed   int `json:"failed"`   // Guests that never got into the park
+}
+
+// CrowdDismissal is the response to sending the guest crowd home
+type CrowdDismissal struct {
+	SentHome int `json:"sent_home"` // Guests asked to leave the park
+}
@@ -0,0 +1,6 @@
+package httptypes
+
+// LogLevel is the level a binary logs at, both as request and response of a log level request
+type LogLevel struct {
+	Level string `json:"level"` // debug, info, warn or error
+}
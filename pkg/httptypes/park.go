@@ -1,12 +1,293 @@
 package httptypes
 
+import "time"
+
 type Park struct {
-	IsClosed   bool    `json:"is_closed"`   // Whether the park is closed
-	TotalSpace float64 `json:"total_space"` // Total space in acres
-	Money      float64 `json:"money"`       // Money in the park
+	IsClosed   bool      `json:"is_closed"`   // Whether the park is closed
+	TotalSpace float64   `json:"total_space"` // Total space in acres
+	Money      float64   `json:"money"`       // Money in the park
+	Guests     int       `json:"guests"`      // Guests currently inside the park
+	Time       time.Time `json:"time"`        // Simulated time in the park
+
+	Bankrupt          bool               `json:"bankrupt"`           // Whether the game is over
+	Rating            float64            `json:"rating"`             // Park rating from 0 to 5 stars
+	Weather           string             `json:"weather"`            // Weather condition today
+	AttractionRatings map[string]float64 `json:"attraction_ratings"` // Ratings by attraction instance
 }
 
+// EnterResponse is sent to a guest admitted to the park
+type EnterResponse struct {
+	GuestID string `json:"guest_id"`
+}
+
+// ExitRequest represents a guest leaving the park
+type ExitRequest struct {
+	GuestID string `json:"guest_id"`
+}
+
+// TransactionCategory classifies money moving in or out of the park
+type TransactionCategory string
+
+// Transaction categories recorded in the park ledger
+const (
+	CategoryEntranceFee TransactionCategory = "entrance_fee"
+	CategoryRideFee     TransactionCategory = "ride_fee"
+	CategoryBuild       TransactionCategory = "build"
+	CategoryRepair      TransactionCategory = "repair"
+	CategoryUpkeep      TransactionCategory = "upkeep"
+	CategoryRefund      TransactionCategory = "refund"
+
+	CategoryLoan          TransactionCategory = "loan"
+	CategoryLoanRepayment TransactionCategory = "loan_repayment"
+	CategoryInterest      TransactionCategory = "interest"
+
+	CategoryWages TransactionCategory = "wages"
+	CategoryLand  TransactionCategory = "land"
+)
+
+// AccountCash is the ledger account holding the park's money
+const AccountCash = "cash"
+
 // TransactionRequest represents a request to send a payment to the park
 type TransactionRequest struct {
+	Amount     float64             `json:"amount"`               // Positive for income, negative for costs
+	Category   TransactionCategory `json:"category"`             // What the money is for
+	Attraction string              `json:"attraction,omitempty"` // Attraction the transaction originates from
+}
+
+// RideReport describes a guest's attempt to use an attraction
+type RideReport struct {
+	Attraction string `json:"attraction"`       // Attraction type
+	Instance   string `json:"instance"`         // Attraction instance
+	Success    bool   `json:"success"`          // Whether the guest got to ride
+	Reason     string `json:"reason,omitempty"` // Why the ride failed
+}
+
+// VisitReport is sent by a guest when it leaves the park
+type VisitReport struct {
+	GuestID      string       `json:"guest_id"`
+	Rides        []RideReport `json:"rides"`        // Every attempt to use an attraction
+	Failures     []string     `json:"failures"`     // Problems not caused by a specific attraction
+	MoneyLeft    float64      `json:"money_left"`   // Money the guest didn't spend
+	Satisfaction float64      `json:"satisfaction"` // Overall satisfaction from 0 to 5 stars
+}
+
+// CredentialsRequest represents a request from an attraction for transaction signing credentials.
+// It must carry the attraction's ServiceAccount token as a bearer token.
+type CredentialsRequest struct {
+	Attraction string `json:"attraction"` // Attraction type
+	Instance   string `json:"instance"`   // Attraction instance the credentials are for
+}
+
+// CredentialsResponse contains the key an attraction signs its transactions with
+type CredentialsResponse struct {
+	Key string `json:"key"`
+}
+
+// LedgerEntry is a double-entry record of a single park transaction
+type LedgerEntry struct {
+	ID         int                 `json:"id"`
+	Time       time.Time           `json:"time"` // Simulated time of the transaction
+	Category   TransactionCategory `json:"category"`
+	Attraction string              `json:"attraction,omitempty"`
+	Instance   string              `json:"instance,omitempty"` // Attraction instance that sent the transaction
+	Debit      string              `json:"debit"`              // Account receiving the money
+	Credit     string              `json:"credit"`             // Account the money comes from
+	Amount     float64             `json:"amount"`             // Always positive
+	Balance    float64             `json:"balance"`            // Park money after the transaction
+}
+
+// Net returns the signed effect of the entry on the park's money
+func (e LedgerEntry) Net() float64 {
+	if e.Credit == AccountCash {
+		return -e.Amount
+	}
+	return e.Amount
+}
+
+// Ledger represents a filtered view of the park ledger
+type Ledger struct {
+	Entries []LedgerEntry `json:"entries"`
+	Net     float64       `json:"net"` // Sum of the signed amounts of all entries
+}
+
+// Clock actions accepted by the park
+const (
+	ClockPause       = "pause"
+	ClockResume      = "resume"
+	ClockSpeed       = "speed"
+	ClockFastForward = "fast-forward"
+)
+
+// Clock represents the state of the park's simulation clock
+type Clock struct {
+	Time   time.Time `json:"time"`   // Current simulated time
+	Paused bool      `json:"paused"` // Whether simulated time is frozen
+	Speed  float64   `json:"speed"`  // Speed multiplier
+}
+
+// ClockRequest represents a request to control the park's simulation clock
+type ClockRequest struct {
+	Action string  `json:"action"`          // One of pause, resume, speed or fast-forward
+	Speed  float64 `json:"speed,omitempty"` // New speed multiplier for the speed action
+}
+
+// DayReport is the financial summary of a single day in the park
+type DayReport struct {
+	Day            int                             `json:"day"`   // Day number, starting at 1
+	Start          time.Time                       `json:"start"` // Simulated time the day started
+	End            time.Time                       `json:"end"`   // Simulated time the park closed
+	Guests         int                             `json:"guests"`
+	Weather        string                          `json:"weather"`           // Weather condition on the day
+	Holiday        string                          `json:"holiday,omitempty"` // Holiday on the day, if any
+	Revenue        map[TransactionCategory]float64 `json:"revenue"`           // Income by category
+	Costs          map[TransactionCategory]float64 `json:"costs"`             // Spending by category
+	Attractions    map[string]AttractionDay        `json:"attractions"`
+	TotalRevenue   float64                         `json:"total_revenue"`
+	TotalCosts     float64                         `json:"total_costs"`
+	Financing      float64                         `json:"financing"` // Loans taken minus loans repaid
+	NetProfit      float64                         `json:"net_profit"`
+	ClosingBalance float64                         `json:"closing_balance"` // Park money at closing time
+	Rating         float64                         `json:"rating"`          // Park rating at closing time
+	LastLedgerID   int                             `json:"last_ledger_id"`  // Last ledger entry included in the report
+}
+
+// AttractionDay is the financial summary of a single attraction instance for a day
+type AttractionDay struct {
+	Attraction string  `json:"attraction"`
+	Rides      int     `json:"rides"`
+	Revenue    float64 `json:"revenue"`
+	Costs      float64 `json:"costs"`
+}
+
+// Loan is money the park borrowed from the bank
+type Loan struct {
+	ID        int       `json:"id"`
+	Principal float64   `json:"principal"` // Amount originally borrowed
+	Balance   float64   `json:"balance"`   // Amount still owed
+	Rate      float64   `json:"rate"`      // Interest charged per simulated day
+	TakenAt   time.Time `json:"taken_at"`  // Simulated time the loan was taken out
+}
+
+// Loans is the state of the park's borrowing
+type Loans struct {
+	Loans       []Loan  `json:"loans"`
+	Debt        float64 `json:"debt"`         // Sum of all loan balances
+	CreditLimit float64 `json:"credit_limit"` // Most the park may owe at once
+	Available   float64 `json:"available"`    // How much more the park can borrow
+	Bankrupt    bool    `json:"bankrupt"`
+}
+
+// LoanRequest represents a request to take out a loan
+type LoanRequest struct {
 	Amount float64 `json:"amount"`
 }
+
+// RepayRequest represents a request to pay back a loan
+type RepayRequest struct {
+	LoanID int     `json:"loan_id"`
+	Amount float64 `json:"amount,omitempty"` // Pays back the whole balance when left out
+}
+
+// Land is the land the park owns and what more costs
+type Land struct {
+	TotalSpace   float64 `json:"total_space"`    // Land the park owns in acres
+	MaxSpace     float64 `json:"max_space"`      // Most land the park may own in acres
+	Available    float64 `json:"available"`      // How many more acres the park can buy
+	PricePerAcre float64 `json:"price_per_acre"` // Price of the next acre
+}
+
+// LandRequest represents a request to buy land
+type LandRequest struct {
+	Acres float64 `json:"acres"`
+}
+
+// Scenario reports the progress through the game's scenario
+type Scenario struct {
+	Name               string      `json:"name"`
+	Description        string      `json:"description,omitempty"`
+	Day                int         `json:"day"`                       // Current day, starting at 1
+	TimeLimitDays      int         `json:"time_limit_days,omitempty"` // Days to meet all objectives, unlimited if 0
+	AllowedAttractions []string    `json:"allowed_attractions,omitempty"`
+	Objectives         []Objective `json:"objectives"`
+	Outcome            string      `json:"outcome"` // in_progress, won or lost
+}
+
+// Objective reports the progress towards a scenario objective
+type Objective struct {
+	Name     string  `json:"name"`
+	Metric   string  `json:"metric"`
+	Target   float64 `json:"target"`
+	ByDay    int     `json:"by_day,omitempty"`
+	Value    float64 `json:"value"`    // Current value of the metric
+	Progress float64 `json:"progress"` // Share of the target reached, from 0 to 1
+	Met      bool    `json:"met"`
+	MetOnDay int     `json:"met_on_day,omitempty"`
+}
+
+// AuditEntry records a change to a setting
+type AuditEntry struct {
+	Time    time.Time `json:"time"`              // Real time of the change
+	SimTime time.Time `json:"sim_time,omitzero"` // Simulated time of the change, if there is one
+	Source  string    `json:"source"`            // Where the change came from, e.g. flags or the settings file
+	Setting string    `json:"setting"`
+	Old     string    `json:"old"`
+	New     string    `json:"new"`
+}
+
+// ParkSettings are the park settings that can be changed while the park runs
+type ParkSettings struct {
+	EntranceFee float64 `json:"entrance_fee"`
+	OpensAt     int     `json:"opens_at"`  // Hour at which the park opens
+	ClosesAt    int     `json:"closes_at"` // Hour at which the park closes
+	Closed      bool    `json:"closed"`    // Whether the park is closed regardless of the hour
+
+	Schedule map[string]Hours `json:"schedule,omitempty"` // Hours by weekday, or holiday, that differ from the above
+}
+
+// ParkSettingsResponse is the response to a park settings request
+type ParkSettingsResponse struct {
+	Settings ParkSettings `json:"settings"`
+	Source   string       `json:"source"` // Where the current settings came from
+	Audit    []AuditEntry `json:"audit"`
+}
+
+// Weather conditions
+const (
+	WeatherSunny = "sunny"
+	WeatherRain  = "rain"
+	WeatherHeat  = "heat"
+	WeatherStorm = "storm"
+)
+
+// Weather is the weather in the park on a simulated day
+type Weather struct {
+	Date      string `json:"date"` // Simulated date, YYYY-MM-DD
+	Condition string `json:"condition"`
+}
+
+// Forecast is the weather today and on the days to come
+type Forecast struct {
+	Today Weather   `json:"today"`
+	Days  []Weather `json:"days"`
+}
+
+// Hours are the opening hours of a day. When the park closes at an earlier hour than it
+// opens, it closes on the next day.
+type Hours struct {
+	OpensAt  int `json:"opens_at"`
+	ClosesAt int `json:"closes_at"`
+}
+
+// CalendarDay describes a simulated day
+type CalendarDay struct {
+	Date    string    `json:"date"` // Simulated date, YYYY-MM-DD
+	Weekday string    `json:"weekday"`
+	Season  string    `json:"season"`
+	Holiday string    `json:"holiday,omitempty"`
+	Opens   time.Time `json:"opens"`
+	Closes  time.Time `json:"closes"`
+	Demand  float64   `json:"demand"` // How much the day scales guest demand
+	Weather string    `json:"weather"`
+	Closed  bool      `json:"closed,omitempty"` // The park's settings keep it closed regardless of its hours
+}
@@ -0,0 +1,29 @@
+package httptypes
+
+import "time"
+
+// Staff roles
+const (
+	RoleMechanic = "mechanic" // Repairs broken attractions
+	RoleJanitor  = "janitor"  // Cleans dirty attractions
+)
+
+// StaffHeartbeat is sent to the park by staff on duty, who are known by the pod their
+// ServiceAccount token is bound to
+type StaffHeartbeat struct {
+	Role string `json:"role"`
+}
+
+// StaffMember is a member of staff on duty
+type StaffMember struct {
+	Name     string    `json:"name"`
+	Role     string    `json:"role"`
+	Wage     float64   `json:"wage"`      // Wage per simulated hour
+	LastSeen time.Time `json:"last_seen"` // Real time of the last heartbeat
+}
+
+// Staff is the response to a staff request
+type Staff struct {
+	Members []StaffMember `json:"members"`
+	Payroll float64       `json:"payroll"` // Wages of all staff on duty per simulated hour
+}
@@ -0,0 +1,219 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Labels attraction pods are recognised by
+const (
+	AttractionNamespace = "attractions"
+	LabelComponent      = "app.kubernetes.io/component"
+	LabelInstance       = "app.kubernetes.io/instance"
+	LabelAttraction     = "attraction"
+)
+
+// discoveryResync is how often the informer replays every cached pod to the handlers
+const discoveryResync = 5 * time.Minute
+
+// AttractionPod is what the cluster knows about a pod running an attraction
+type AttractionPod struct {
+	Pod        string // Pod name
+	Attraction string // Attraction type
+	Instance   string // Attraction instance
+	IP         string
+	Ready      bool
+}
+
+// URL returns where the attraction pod can be reached, empty if it has no IP yet
+func (p AttractionPod) URL() string {
+	if p.IP == "" {
+		return ""
+	}
+	return "http://" + p.IP
+}
+
+// AttractionEvent tells subscribers an attraction pod changed or went away
+type AttractionEvent struct {
+	Pod     AttractionPod
+	Deleted bool
+}
+
+// Discovery watches the attraction pods in the cluster and keeps a cache of them,
+// so the API server is only asked for changes instead of being listed on every call
+type Discovery struct {
+	factory informers.SharedInformerFactory
+	synced  cache.InformerSynced
+
+	mu          sync.RWMutex
+	pods        map[string]AttractionPod // Attraction pods by pod name
+	subscribers []chan AttractionEvent
+}
+
+// NewDiscovery creates a discovery cache of the attraction pods
+func NewDiscovery(clientset kubernetes.Interface) (*Discovery, error) {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, discoveryResync,
+		informers.WithNamespace(AttractionNamespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = LabelComponent + "=attraction"
+		}),
+	)
+
+	d := &Discovery{
+		factory: factory,
+		pods:    make(map[string]AttractionPod),
+	}
+
+	informer := factory.Core().V1().Pods().Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			d.update(obj)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			d.update(obj)
+		},
+		DeleteFunc: d.delete,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to watch attraction pods: %v", err)
+	}
+	d.synced = informer.HasSynced
+
+	return d, nil
+}
+
+// Start watches the attraction pods until the context is done, and waits for the cache to fill
+func (d *Discovery) Start(ctx context.Context) error {
+	d.factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), d.synced) {
+		return fmt.Errorf("failed to sync attraction pods")
+	}
+	return nil
+}
+
+// List returns the cached attraction pods ordered by instance
+func (d *Discovery) List() []AttractionPod {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	pods := make([]AttractionPod, 0, len(d.pods))
+	for _, pod := range d.pods {
+		pods = append(pods, pod)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Instance < pods[j].Instance
+	})
+	return pods
+}
+
+// Get returns the ready pod running an attraction instance, if there is one
+func (d *Discovery) Get(instance string) (AttractionPod, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, pod := range d.pods {
+		if pod.Instance == instance && pod.Ready {
+			return pod, true
+		}
+	}
+	return AttractionPod{}, false
+}
+
+// Pod returns the cached attraction pod with the given name, if it exists
+func (d *Discovery) Pod(name string) (AttractionPod, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	pod, ok := d.pods[name]
+	return pod, ok
+}
+
+// Subscribe returns a channel that receives every change to the attraction pods.
+// Events are dropped for subscribers that fall too far behind.
+func (d *Discovery) Subscribe() <-chan AttractionEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	events := make(chan AttractionEvent, 64)
+	d.subscribers = append(d.subscribers, events)
+	return events
+}
+
+// update caches an added or changed pod and notifies subscribers
+func (d *Discovery) update(obj interface{}) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	attraction := attractionPod(pod)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if previous, ok := d.pods[pod.Name]; ok && previous == attraction {
+		return
+	}
+	d.pods[pod.Name] = attraction
+	d.notify(AttractionEvent{Pod: attraction})
+}
+
+// delete forgets a deleted pod and notifies subscribers
+func (d *Discovery) delete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	attraction, ok := d.pods[pod.Name]
+	if !ok {
+		return
+	}
+	delete(d.pods, pod.Name)
+	d.notify(AttractionEvent{Pod: attraction, Deleted: true})
+}
+
+// notify sends an event to every subscriber without waiting on slow ones.
+// It must only be called while holding the lock.
+func (d *Discovery) notify(event AttractionEvent) {
+	for _, subscriber := range d.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// attractionPod returns what discovery needs to know about a pod
+func attractionPod(pod *corev1.Pod) AttractionPod {
+	ready := false
+	if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady {
+				ready = condition.Status == corev1.ConditionTrue
+			}
+		}
+	}
+
+	return AttractionPod{
+		Pod:        pod.Name,
+		Attraction: pod.Labels[LabelAttraction],
+		Instance:   pod.Labels[LabelInstance],
+		IP:         pod.Status.PodIP,
+		Ready:      ready,
+	}
+}
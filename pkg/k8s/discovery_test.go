@@ -0,0 +1,106 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testPod(name, instance string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: AttractionNamespace,
+			Labels: map[string]string{
+				LabelComponent:  "attraction",
+				LabelAttraction: "carousel",
+				LabelInstance:   instance,
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			PodIP:      "10.0.0.1",
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		testPod("carousel-1-abc", "carousel-1", true),
+		testPod("carousel-2-def", "carousel-2", false),
+	)
+
+	discovery, err := NewDiscovery(clientset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := discovery.Subscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := discovery.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	pods := discovery.List()
+	if len(pods) != 2 || pods[0].Instance != "carousel-1" || pods[1].Instance != "carousel-2" {
+		t.Fatalf("List() = %+v, want carousel-1 and carousel-2", pods)
+	}
+
+	pod, ok := discovery.Get("carousel-1")
+	if !ok || pod.Pod != "carousel-1-abc" || pod.URL() != "http://10.0.0.1" {
+		t.Errorf("Get(carousel-1) = %+v, %v, want the ready pod", pod, ok)
+	}
+	if _, ok := discovery.Get("carousel-2"); ok {
+		t.Errorf("Get(carousel-2) found a pod that isn't ready")
+	}
+	if pod, ok := discovery.Pod("carousel-2-def"); !ok || pod.Ready {
+		t.Errorf("Pod(carousel-2-def) = %+v, %v, want the pod that isn't ready", pod, ok)
+	}
+
+	// Subscribers hear about the cached pods and later deletions
+	for range 2 {
+		<-events
+	}
+	if err := clientset.CoreV1().Pods(AttractionNamespace).Delete(ctx, "carousel-1-abc", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if !event.Deleted || event.Pod.Instance != "carousel-1" {
+			t.Errorf("event = %+v, want carousel-1 deleted", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event for the deleted pod")
+	}
+	if _, ok := discovery.Get("carousel-1"); ok {
+		t.Errorf("Get(carousel-1) found a deleted pod")
+	}
+}
+
+func TestAttractionPodReady(t *testing.T) {
+	pod := testPod("carousel-1-abc", "carousel-1", true)
+	if !attractionPod(pod).Ready {
+		t.Errorf("running pod with a ready condition isn't ready")
+	}
+
+	pod.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	if attractionPod(pod).Ready {
+		t.Errorf("terminating pod is ready")
+	}
+
+	pod = testPod("carousel-1-abc", "carousel-1", true)
+	pod.Status.Phase = corev1.PodPending
+	if attractionPod(pod).Ready {
+		t.Errorf("pending pod is ready")
+	}
+}
@@ -2,16 +2,10 @@ package k8s
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"kubepark/pkg/httptypes"
-	"net/http"
-	"os"
-	"time"
+	"strings"
 
-	v1 "k8s.io/api/batch/v1"
-	corev1 "k8s.io/api/core/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -33,101 +27,56 @@ func NewClient() (*kubernetes.Clientset, error) {
 	return clientset, nil
 }
 
-func DiscoverServices(endpoint string, v *[]interface{}, decoder func(r io.Reader, v *[]interface{}, ip string) error) error {
-	clientset, err := NewClient()
-	if err != nil {
-		return fmt.Errorf("failed to create client: %v", err)
-	}
-
-	// Get all pods in the cluster
-	pods, err := clientset.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to list pods: %v", err)
-	}
-
-	client := &http.Client{
-		Timeout: time.Second * 2,
-	}
-
-	// Check each pod for the specified endpoint
-	for _, pod := range pods.Items {
-		if pod.Status.Phase != corev1.PodRunning {
-			continue
-		}
-
-		// Skip the current pod
-		if pod.Name == os.Getenv("HOSTNAME") {
-			continue
-		}
+// podNameExtra is the extra the API server adds to a bound ServiceAccount token's user info
+// with the name of the pod the token was issued to
+const podNameExtra = "authentication.kubernetes.io/pod-name"
 
-		// Try to connect to the pod's IP
-		podIP := pod.Status.PodIP
-		if podIP == "" {
-			continue
-		}
-
-		// Check if this is an attraction
-		resp, err := client.Get(fmt.Sprintf("http://%s/%s", podIP, endpoint))
-		if err != nil {
-			continue // Skip if we can't connect
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == http.StatusOK {
-			decoder(resp.Body, v, podIP)
-		}
-	}
-
-	return nil
+// TokenUser is who a reviewed ServiceAccount token belongs to
+type TokenUser struct {
+	Username string
+	Pod      string // Pod the token is bound to, empty if it isn't bound to one
 }
 
-func DiscoverGuests() (*v1.JobList, error) {
-	clientset, err := NewClient()
+// StaffServiceAccountPrefix is the username prefix of the ServiceAccounts staff work as
+const StaffServiceAccountPrefix = "system:serviceaccount:staff:"
+
+// ReviewToken asks the API server who a ServiceAccount token belongs to
+func ReviewToken(ctx context.Context, clientset kubernetes.Interface, token string) (TokenUser, error) {
+	review, err := clientset.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: token,
+		},
+	}, metav1.CreateOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create client: %v", err)
+		return TokenUser{}, fmt.Errorf("failed to review token: %v", err)
 	}
 
-	jobs, err := clientset.BatchV1().Jobs("guests").List(context.TODO(), metav1.ListOptions{
-		FieldSelector: "status.phase=Running",
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to list jobs: %v", err)
+	if !review.Status.Authenticated {
+		return TokenUser{}, fmt.Errorf("token not authenticated: %s", review.Status.Error)
 	}
 
-	return jobs, nil
+	user := TokenUser{Username: review.Status.User.Username}
+	if pods := review.Status.User.Extra[podNameExtra]; len(pods) > 0 {
+		user.Pod = pods[0]
+	}
+	return user, nil
 }
 
-func DiscoverAttractions() ([]httptypes.Attraction, error) {
-	var attractions []interface{}
-
-	decoder := func(r io.Reader, v *[]interface{}, ip string) error {
-		var attraction httptypes.Attraction
-		if err := json.NewDecoder(r).Decode(&attraction); err != nil {
-			return err
-		}
-
-		*v = append(*v, httptypes.Attraction{
-			URL:  fmt.Sprintf("http://%s", ip),
-			Fee:  attraction.Fee,
-			Size: attraction.Size,
-		})
-
-		return nil
+// ReviewStaffToken reviews the ServiceAccount token of a member of staff, and returns the name of
+// the pod the token is bound to, which is who the member of staff is
+func ReviewStaffToken(ctx context.Context, clientset kubernetes.Interface, token string) (string, error) {
+	user, err := ReviewToken(ctx, clientset, token)
+	if err != nil {
+		return "", err
 	}
 
-	err := DiscoverServices("/attraction-status", &attractions, decoder)
-
-	if err != nil {
-		return nil, err
+	if !strings.HasPrefix(user.Username, StaffServiceAccountPrefix) {
+		return "", fmt.Errorf("service account %s is not staff", user.Username)
 	}
 
-	var typedAttractions []httptypes.Attraction
-	for _, a := range attractions {
-		if attr, ok := a.(httptypes.Attraction); ok {
-			typedAttractions = append(typedAttractions, attr)
-		}
+	if user.Pod == "" {
+		return "", fmt.Errorf("token of %s is not bound to a pod", user.Username)
 	}
 
-	return typedAttractions, nil
+	return user.Pod, nil
 }
@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"kubepark/pkg/httptypes"
+)
+
+// HandleLevel returns the handler that shows the log level, and changes it on POST
+func HandleLevel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var req httptypes.LogLevel
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			old := Level()
+			if err := SetLevel(req.Level); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			slog.Info("Changed log level", "old", levelName(old), "new", levelName(Level()))
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(httptypes.LogLevel{Level: levelName(Level())})
+	}
+}
+
+// levelName returns the name of a log level the way it's given in flags
+func levelName(l slog.Level) string {
+	return strings.ToLower(l.String())
+}
@@ -1,45 +1,109 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"strings"
 )
 
+// Formats logs can be written in
+const (
+	FormatText = "text" // logfmt, easy to read in a terminal
+	FormatJSON = "json" // One JSON object per line, easy to parse in Loki
+)
+
+// Options configure the logger of a binary
+type Options struct {
+	Level     string // Level to log at (debug, info, warn, error), info if empty
+	Format    string // Format to log in (text, json), text if empty
+	Component string // Which part of the park logs, e.g. park, attraction, guest or staff
+	Attrs     []any  // Attributes every line carries, e.g. the attraction's name and instance
+}
+
 var Logger *slog.Logger
 
-// InitLogger initializes the global logger with the specified level
-func InitLogger(level string) {
-	var logLevel slog.Level
+// level is the level the logger logs at, which can be changed while it runs
+var level = new(slog.LevelVar)
 
-	switch strings.ToLower(level) {
-	case "debug":
-		logLevel = slog.LevelDebug
-	case "info":
-		logLevel = slog.LevelInfo
-	case "warn", "warning":
-		logLevel = slog.LevelWarn
-	case "error":
-		logLevel = slog.LevelError
-	default:
+// Init initializes the global logger. An unknown level falls back to info, and an unknown
+// format to text, so a typo never keeps a binary from starting.
+func Init(opts Options) {
+	logLevel, err := ParseLevel(opts.Level)
+	if err != nil {
 		logLevel = slog.LevelInfo
 	}
+	level.Set(logLevel)
 
-	opts := &slog.HandlerOptions{
-		Level: logLevel,
-	}
-
-	handler := slog.NewTextHandler(os.Stdout, opts)
+	handler := newHandler(os.Stdout, opts.Format)
 	Logger = slog.New(handler)
+	if opts.Component != "" {
+		Logger = Logger.With("component", opts.Component)
+	}
+	Logger = Logger.With(opts.Attrs...)
 
 	// Set as default logger
 	slog.SetDefault(Logger)
+
+	if err != nil {
+		Logger.Warn("Unknown log level, logging at info", "level", opts.Level)
+	}
+}
+
+// newHandler creates the handler writing logs in the format. Logs written with a context
+// carry its trace and span IDs, and every log carries the simulated time once it's known.
+func newHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{
+		Level: level,
+	}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		handler = slog.NewTextHandler(w, opts)
+	}
+
+	return simTimeHandler{traceHandler{handler}}
+}
+
+// ParseLevel parses the name of a log level
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+	}
+}
+
+// Level returns the level the logger logs at
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel changes the level the logger logs at while it runs
+func SetLevel(name string) error {
+	logLevel, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(logLevel)
+	return nil
 }
 
 // GetLogger returns the global logger instance
 func GetLogger() *slog.Logger {
 	if Logger == nil {
-		InitLogger("info")
+		Init(Options{})
 	}
 	return Logger
 }
@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSimTime(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(newHandler(&buf, FormatJSON))
+
+	simTime.Store(0)
+	log.Info("before the park was reached")
+
+	now := time.Date(2025, 6, 1, 13, 30, 0, 0, time.UTC)
+	SetSimTime(now)
+	log.Info("after the park was reached")
+
+	var lines []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var fields map[string]any
+		if err := json.Unmarshal(line, &fields); err != nil {
+			t.Fatalf("failed to parse log line %q: %v", line, err)
+		}
+		lines = append(lines, fields)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2", len(lines))
+	}
+
+	if _, ok := lines[0]["sim_time"]; ok {
+		t.Errorf("sim_time = %v while it is unknown, want none", lines[0]["sim_time"])
+	}
+	got, err := time.Parse(time.RFC3339Nano, lines[1]["sim_time"].(string))
+	if err != nil || !got.Equal(now) {
+		t.Errorf("sim_time = %v, want %v", lines[1]["sim_time"], now)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"", slog.LevelInfo, false},
+		{"debug", slog.LevelDebug, false},
+		{"WARN", slog.LevelWarn, false},
+		{"warning", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", slog.LevelInfo, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLevel(tt.name)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseLevel(%q) = %v, %v, want %v, error %v", tt.name, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
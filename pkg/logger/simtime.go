@@ -0,0 +1,42 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// simTime is the simulated time in the park in Unix nanoseconds, 0 while it is unknown.
+// It is set by whoever knows the time instead of asked for by the handler, so logging never
+// takes a lock the code writing the log might be holding.
+var simTime atomic.Int64
+
+// SetSimTime stamps every log from now on with the simulated time, until it is set again
+func SetSimTime(t time.Time) {
+	simTime.Store(t.UnixNano())
+}
+
+// simTimeHandler adds the simulated time to every record, so logs can be matched up with
+// the park's days rather than only the real time they were written at
+type simTimeHandler struct {
+	slog.Handler
+}
+
+// Handle adds the simulated time to the record and passes it on
+func (h simTimeHandler) Handle(ctx context.Context, r slog.Record) error {
+	if now := simTime.Load(); now != 0 {
+		r.AddAttrs(slog.Time("sim_time", time.Unix(0, now)))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a sim time handler with the attributes added
+func (h simTimeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return simTimeHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a sim time handler with the group opened
+func (h simTimeHandler) WithGroup(name string) slog.Handler {
+	return simTimeHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,204 @@
+package settings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"kubepark/pkg/httptypes"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/yaml"
+)
+
+// Interval is how often a settings file is checked for changes
+const Interval = 5 * time.Second
+
+// Where the applied settings came from, in order of precedence
+const (
+	SourceFile  = "settings_file"
+	SourceFlags = "flags"
+)
+
+// Watcher polls a settings file, e.g. mounted from a ConfigMap, and applies it whenever it changes
+type Watcher struct {
+	path     string
+	interval time.Duration
+	apply    func(data []byte) error
+	last     []byte
+}
+
+// NewWatcher creates a watcher that calls apply with the contents of the file whenever
+// they change. A missing file, or no path at all, is passed on as empty contents.
+func NewWatcher(path string, interval time.Duration, apply func(data []byte) error) *Watcher {
+	return &Watcher{
+		path:     path,
+		interval: interval,
+		apply:    apply,
+	}
+}
+
+// Load reads the file and applies it if it changed since the last load.
+// Contents that failed to apply are not retried until they change again.
+func (w *Watcher) Load() error {
+	var data []byte
+	if w.path != "" {
+		var err error
+		data, err = os.ReadFile(w.path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to read settings: %w", err)
+		}
+	}
+
+	if w.last != nil && bytes.Equal(data, w.last) {
+		return nil
+	}
+
+	w.last = append([]byte{}, data...)
+	return w.apply(w.last)
+}
+
+// Start keeps loading the file in the background until the context is done
+func (w *Watcher) Start(ctx context.Context) {
+	if w.path == "" {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := w.Load(); err != nil {
+				slog.Error("Failed to apply settings, keeping the current ones", "path", w.path, "error", err)
+			}
+		}
+	}()
+}
+
+// Parse overlays the YAML or JSON settings onto v, so settings left out keep their value
+func Parse(data []byte, v interface{}) error {
+	if err := yaml.UnmarshalStrict(data, v); err != nil {
+		return fmt.Errorf("failed to parse settings: %w", err)
+	}
+	return nil
+}
+
+// Diff returns an audit entry for every setting that differs between old and new,
+// which have to be structs of the same type
+func Diff(source string, simTime time.Time, old interface{}, new interface{}) []httptypes.AuditEntry {
+	oldValues, newValues := toMap(old), toMap(new)
+
+	keys := make([]string, 0, len(newValues))
+	for key := range newValues {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var entries []httptypes.AuditEntry
+	for _, key := range keys {
+		if oldValues[key] == newValues[key] {
+			continue
+		}
+
+		entries = append(entries, httptypes.AuditEntry{
+			Time:    time.Now(),
+			SimTime: simTime,
+			Source:  source,
+			Setting: key,
+			Old:     oldValues[key],
+			New:     newValues[key],
+		})
+	}
+
+	return entries
+}
+
+// toMap flattens a struct into its JSON field names and values
+func toMap(v interface{}) map[string]string {
+	data, _ := json.Marshal(v)
+
+	var fields map[string]json.RawMessage
+	json.Unmarshal(data, &fields)
+
+	values := make(map[string]string, len(fields))
+	for key, value := range fields {
+		values[key] = string(value)
+	}
+	return values
+}
+
+// Applier applies a settings file on top of the settings given as flags
+type Applier[T any] struct {
+	Component string   // What the settings are of in logs, e.g. "park"
+	Flags     func() T // Settings given on the command line, or their defaults
+
+	// Validate checks that the component can run with the settings
+	Validate func(settings T) error
+
+	// Store persists the settings and where they came from, and returns what changed
+	Store func(settings T, source string) ([]httptypes.AuditEntry, error)
+
+	// Applied is called once the settings are stored, e.g. to update metrics
+	Applied func(settings T)
+
+	// Reloads counts the settings applied and rejected by result
+	Reloads *prometheus.CounterVec
+}
+
+// Apply is what a Watcher applies the settings file with. Settings in the file take precedence
+// over flags, and settings left out of it fall back to their flag. The stored settings only
+// record what was applied last, they never override the configured ones.
+func (a *Applier[T]) Apply(data []byte) error {
+	configured := a.Flags()
+	source := SourceFlags
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := Parse(data, &configured); err != nil {
+			a.Reloads.WithLabelValues("invalid").Inc()
+			return err
+		}
+		source = SourceFile
+	}
+
+	if err := a.Validate(configured); err != nil {
+		a.Reloads.WithLabelValues("invalid").Inc()
+		return fmt.Errorf("invalid settings: %w", err)
+	}
+
+	changes, err := a.Store(configured, source)
+	if err != nil {
+		return fmt.Errorf("failed to store settings: %w", err)
+	}
+	a.Reloads.WithLabelValues("applied").Inc()
+
+	for _, change := range changes {
+		slog.Info("Changed "+a.Component+" setting", "setting", change.Setting, "old", change.Old, "new", change.New, "source", change.Source)
+	}
+	if a.Applied != nil {
+		a.Applied(configured)
+	}
+
+	return nil
+}
+
+// Audit appends the changes to an audit log and keeps its most recent entries up to limit
+func Audit(log []httptypes.AuditEntry, changes []httptypes.AuditEntry, limit int) []httptypes.AuditEntry {
+	log = append(log, changes...)
+	if len(log) > limit {
+		log = log[len(log)-limit:]
+	}
+	return log
+}
@@ -0,0 +1,166 @@
+package settings
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"kubepark/pkg/httptypes"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type testSettings struct {
+	EntranceFee float64 `json:"entrance_fee"`
+	Closed      bool    `json:"closed"`
+}
+
+func TestWatcherLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+
+	var applied []string
+	fail := false
+	watcher := NewWatcher(path, time.Second, func(data []byte) error {
+		applied = append(applied, string(data))
+		if fail {
+			return errors.New("invalid settings")
+		}
+		return nil
+	})
+
+	// A missing file is applied as empty contents, once
+	for range 2 {
+		if err := watcher.Load(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(applied) != 1 || applied[0] != "" {
+		t.Fatalf("applied %q without a file, want empty contents once", applied)
+	}
+
+	// Changed contents are applied, unchanged ones aren't
+	if err := os.WriteFile(path, []byte("entrance_fee: 20\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := watcher.Load(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(applied) != 2 || applied[1] != "entrance_fee: 20\n" {
+		t.Fatalf("applied %q, want the file applied once", applied)
+	}
+
+	// Contents that failed to apply aren't retried until they change
+	fail = true
+	if err := os.WriteFile(path, []byte("entrance_fee: -1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.Load(); err == nil {
+		t.Errorf("Load() of contents that failed to apply succeeded, want the error")
+	}
+	if err := watcher.Load(); err != nil || len(applied) != 3 {
+		t.Errorf("Load() retried contents that failed to apply: %q, %v", applied, err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	settings := testSettings{EntranceFee: 10, Closed: true}
+	if err := Parse([]byte("entrance_fee: 25"), &settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings.EntranceFee != 25 || !settings.Closed {
+		t.Errorf("Parse() = %+v, want the fee overlaid and closed kept", settings)
+	}
+
+	if err := Parse([]byte(`{"closed": false}`), &settings); err != nil || settings.Closed {
+		t.Errorf("Parse() of JSON = %+v, %v, want closed cleared", settings, err)
+	}
+
+	if err := Parse([]byte("entrance_feee: 25"), &settings); err == nil {
+		t.Errorf("Parse() of an unknown setting succeeded, want an error")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	simTime := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	entries := Diff("settings_file", simTime, testSettings{EntranceFee: 10}, testSettings{EntranceFee: 25, Closed: true})
+
+	if len(entries) != 2 {
+		t.Fatalf("Diff() = %+v, want 2 changes", entries)
+	}
+
+	// Changes are ordered by setting
+	closed, fee := entries[0], entries[1]
+	if closed.Setting != "closed" || closed.Old != "false" || closed.New != "true" {
+		t.Errorf("Diff()[0] = %+v, want closed from false to true", closed)
+	}
+	if fee.Setting != "entrance_fee" || fee.Old != "10" || fee.New != "25" || fee.Source != "settings_file" || !fee.SimTime.Equal(simTime) {
+		t.Errorf("Diff()[1] = %+v, want entrance_fee from 10 to 25 from the settings file", fee)
+	}
+
+	if entries := Diff("flags", simTime, testSettings{}, testSettings{}); len(entries) != 0 {
+		t.Errorf("Diff() of equal settings = %+v, want none", entries)
+	}
+}
+
+func TestApplierApply(t *testing.T) {
+	var stored []string
+	current := testSettings{}
+	applier := &Applier[testSettings]{
+		Component: "test",
+		Flags: func() testSettings {
+			return testSettings{EntranceFee: 10}
+		},
+		Validate: func(s testSettings) error {
+			if s.EntranceFee < 0 {
+				return errors.New("entrance_fee must not be negative")
+			}
+			return nil
+		},
+		Store: func(s testSettings, source string) ([]httptypes.AuditEntry, error) {
+			changes := Diff(source, time.Time{}, current, s)
+			current = s
+			stored = append(stored, source)
+			return changes, nil
+		},
+		Reloads: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "settings_reloads_total"}, []string{"result"}),
+	}
+
+	// The file overlays the flags
+	if err := applier.Apply([]byte("closed: true")); err != nil {
+		t.Fatal(err)
+	}
+	if current != (testSettings{EntranceFee: 10, Closed: true}) {
+		t.Errorf("stored %+v, want the flag fee and the file's closed", current)
+	}
+
+	// Without a file the flags apply alone
+	if err := applier.Apply(nil); err != nil {
+		t.Fatal(err)
+	}
+	if current != (testSettings{EntranceFee: 10}) || !slices.Equal(stored, []string{SourceFile, SourceFlags}) {
+		t.Errorf("stored %+v from %v, want the flags from the file then the flags", current, stored)
+	}
+
+	// Invalid settings aren't stored
+	for _, data := range []string{"entrance_fee: -1", "entrance_feee: 25"} {
+		if err := applier.Apply([]byte(data)); err == nil {
+			t.Errorf("Apply(%q) succeeded, want an error", data)
+		}
+	}
+	if len(stored) != 2 {
+		t.Errorf("stored invalid settings: %v", stored)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	log := []httptypes.AuditEntry{{Setting: "a"}, {Setting: "b"}}
+	log = Audit(log, []httptypes.AuditEntry{{Setting: "c"}, {Setting: "d"}}, 3)
+	if len(log) != 3 || log[0].Setting != "b" || log[2].Setting != "d" {
+		t.Errorf("Audit() = %+v, want b to d", log)
+	}
+}
@@ -6,12 +6,17 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
+// modifySaveInterval is how long changes made with Modify may go unsaved
+const modifySaveInterval = 10 * time.Second
+
 // Manager manages persistent state
 type Manager struct {
 	state      interface{}
 	volumePath string
+	saved      time.Time // When the state was last saved
 	mu         sync.RWMutex
 }
 
@@ -54,7 +59,7 @@ func (s *Manager) Load() error {
 	return nil
 }
 
-// Save saves the state to disk
+// Save saves the state to disk. It must only be called while holding the lock.
 func (s *Manager) Save() error {
 	if s.volumePath == "" {
 		return nil
@@ -78,14 +83,15 @@ func (s *Manager) Save() error {
 		return fmt.Errorf("failed to rename temporary file: %w", err)
 	}
 
+	s.saved = time.Now()
 	return nil
 }
 
-// Get returns the current state
-func (s *Manager) Get() interface{} {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.state
+// Flush saves the state to disk while holding the lock, so it can't catch an update halfway
+func (s *Manager) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Save()
 }
 
 // Set updates the state and saves it to disk
@@ -95,3 +101,33 @@ func (s *Manager) Set(newState interface{}) error {
 	s.state = newState
 	return s.Save()
 }
+
+// Update applies the updater to the state and saves it to disk while holding the lock,
+// so concurrent updates can't interleave
+func (s *Manager) Update(updater func(state interface{})) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updater(s.state)
+	return s.Save()
+}
+
+// Modify applies the updater to the state while holding the lock like Update, but only saves
+// it to disk if it went unsaved for a while. It is meant for frequent changes that are cheap to
+// lose, which are also saved with the next Update or Flush.
+func (s *Manager) Modify(updater func(state interface{})) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updater(s.state)
+	if time.Since(s.saved) < modifySaveInterval {
+		return nil
+	}
+	return s.Save()
+}
+
+// View calls the viewer with the state while holding the read lock, so it can safely
+// copy parts of the state that concurrent updates modify in place
+func (s *Manager) View(viewer func(state interface{})) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	viewer(s.state)
+}
@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters spans can be sent to, picked with OTEL_TRACES_EXPORTER
+const (
+	ExporterOTLP    = "otlp"    // An OTLP endpoint over HTTP, e.g. a local collector
+	ExporterConsole = "console" // Printed to stdout, a stand-in when there's no collector
+	ExporterNone    = "none"    // Not exported, spans only correlate logs
+)
+
+const (
+	// tracerName names the tracer of the spans kubepark starts itself
+	tracerName = "kubepark"
+
+	// shutdownTimeout is how long the spans left get to be sent before exiting
+	shutdownTimeout = 5 * time.Second
+)
+
+// Init sets up tracing for a service and passes traces on through HTTP headers. Spans are
+// exported as set by the standard OpenTelemetry environment variables, to the OTLP endpoint
+// in OTEL_EXPORTER_OTLP_ENDPOINT by default. Without an endpoint spans aren't exported, but
+// still give logs trace IDs. The returned function sends the spans left before exiting.
+func Init(ctx context.Context, service string) (func() error, error) {
+	exporter, err := newExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(service),
+		semconv.ServiceInstanceID(os.Getenv("HOSTNAME")),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe tracing resource: %w", err)
+	}
+
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if exporter != nil {
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+	provider := sdktrace.NewTracerProvider(options...)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	shutdown := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return provider.Shutdown(ctx)
+	}
+	return shutdown, nil
+}
+
+// newExporter creates the span exporter picked with OTEL_TRACES_EXPORTER, none if there's nowhere to send spans
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	name := os.Getenv("OTEL_TRACES_EXPORTER")
+	if name == "" {
+		name = ExporterNone
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+			name = ExporterOTLP
+		}
+	}
+
+	switch name {
+	case ExporterOTLP:
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		return exporter, nil
+	case ExporterConsole:
+		exporter, err := stdouttrace.New()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create console exporter: %w", err)
+		}
+		return exporter, nil
+	case ExporterNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q, expected %s, %s or %s", name, ExporterOTLP, ExporterConsole, ExporterNone)
+	}
+}
+
+// Start starts a span as a child of the span in the context, if any
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// Handler traces the requests served by a handler, continuing the trace of the caller
+func Handler(handler http.Handler, service string) http.Handler {
+	return otelhttp.NewHandler(handler, service)
+}
+
+// NewClient creates an HTTP client that passes the trace of each request's context on to the
+// service it calls. A timeout of 0 means no timeout.
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+		Timeout:   timeout,
+	}
+}
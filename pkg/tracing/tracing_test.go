@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewExporter(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		endpoint string
+		want     bool
+		wantErr  bool
+	}{
+		{"nowhere to send spans", "", "", false, false},
+		{"OTLP endpoint", "", "http://localhost:4318", true, false},
+		{"console", ExporterConsole, "", true, false},
+		{"none despite an endpoint", ExporterNone, "http://localhost:4318", false, false},
+		{"unknown", "zipkin", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OTEL_TRACES_EXPORTER", tt.exporter)
+			t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", tt.endpoint)
+			t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+			exporter, err := newExporter(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newExporter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := exporter != nil; got != tt.want {
+				t.Errorf("newExporter() = %v, want an exporter %v", exporter, tt.want)
+			}
+		})
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", ExporterNone)
+	shutdown, err := Init(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown()
+
+	// The server continues the trace of the client's span
+	var served trace.SpanContext
+	server := httptest.NewServer(Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = trace.SpanContextFromContext(r.Context())
+	}), "test"))
+	defer server.Close()
+
+	ctx, span := Start(context.Background(), "visit")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := NewClient(0).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if !served.IsValid() || served.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("served trace = %v, want %v", served.TraceID(), span.SpanContext().TraceID())
+	}
+	if served.SpanID() == span.SpanContext().SpanID() {
+		t.Errorf("server reused the client's span instead of starting a child")
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"kubepark/pkg/auth"
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/logger"
+	"kubepark/pkg/tracing"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// heartbeatInterval is how often staff tell the park they are on duty
+	heartbeatInterval = 10 * time.Second
+
+	// patrolInterval is how often staff look for work
+	patrolInterval = 5 * time.Second
+
+	// heartbeatTimeout is how long a heartbeat may take before it is given up on until the next one
+	heartbeatTimeout = 5 * time.Second
+)
+
+// parkClient passes the trace of a heartbeat on to the park
+var parkClient = tracing.NewClient(heartbeatTimeout)
+
+// Config represents the staff configuration
+type Config struct {
+	Role      string
+	Name      string
+	ParkURL   string
+	LogLevel  string
+	LogFormat string
+}
+
+// Staff represents a member of staff working in the park
+type Staff struct {
+	Config        *Config
+	MetricsServer *http.Server
+	Work          func(ctx context.Context, parkURL string) error // Does one round of work
+
+	shutdownTracing func() error
+}
+
+var metrics = struct {
+	Jobs *prometheus.CounterVec
+}{
+	Jobs: prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "staff_jobs_total",
+			Help: "Number of jobs done by staff",
+		},
+		[]string{"role", "result"},
+	),
+}
+
+// New creates a new member of staff
+func New() *Staff {
+	// The park knows staff by the pod their ServiceAccount token is bound to, so go by the pod name
+	config := &Config{Name: os.Getenv("HOSTNAME")}
+
+	flag.StringVar(&config.Role, "role", httptypes.RoleMechanic, "Role of the staff member (mechanic, janitor)")
+	flag.StringVar(&config.ParkURL, "park-url", "http://kubepark:80", "URL of the kubepark service")
+	flag.StringVar(&config.LogLevel, "log-level", "info", "Log level (debug, info, warn, error)")
+	flag.StringVar(&config.LogFormat, "log-format", logger.FormatText, "Log format (text, json)")
+	flag.Parse()
+
+	// Initialize logger with configured level and format
+	logger.Init(logger.Options{
+		Level:     config.LogLevel,
+		Format:    config.LogFormat,
+		Component: "staff",
+		Attrs:     []any{"role", config.Role, "name", config.Name},
+	})
+
+	var work func(ctx context.Context, parkURL string) error
+	switch config.Role {
+	case httptypes.RoleMechanic:
+		work = repairBroken
+	case httptypes.RoleJanitor:
+		work = cleanDirtiest
+	default:
+		err := fmt.Errorf("unknown staff role %q", config.Role)
+		slog.Error("Failed to start staff", "error", err)
+		panic(err)
+	}
+
+	// Trace the jobs staff do at the attractions
+	shutdownTracing, err := tracing.Init(context.Background(), "staff")
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "error", err)
+		panic(err)
+	}
+
+	// Create metrics server on port 9000
+	r := prometheus.NewRegistry()
+	r.MustRegister(metrics.Jobs)
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", promhttp.HandlerFor(r, promhttp.HandlerOpts{}))
+	metricsMux.HandleFunc("/log-level", logger.HandleLevel())
+	metricsServer := &http.Server{
+		Addr:    ":9000",
+		Handler: metricsMux,
+	}
+
+	return &Staff{
+		Config:        config,
+		MetricsServer: metricsServer,
+		Work:          work,
+
+		shutdownTracing: shutdownTracing,
+	}
+}
+
+// Start reports for duty and works until the context is done
+func (s *Staff) Start(ctx context.Context) error {
+	// Start metrics server
+	go func() {
+		slog.Info("Starting metrics server on port 9000")
+		if err := s.MetricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Metrics server failed", "error", err)
+			panic(err)
+		}
+	}()
+
+	// The park pays staff for as long as they keep sending heartbeats
+	if err := s.heartbeat(ctx); err != nil {
+		return fmt.Errorf("failed to report for duty: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := s.heartbeat(ctx); err != nil {
+				slog.Warn("Failed to send heartbeat to park", "error", err)
+			}
+		}
+	}()
+
+	slog.Info("Staff on duty", "name", s.Config.Name, "role", s.Config.Role)
+
+	ticker := time.NewTicker(patrolInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Staff going off duty", "name", s.Config.Name, "role", s.Config.Role)
+			return errors.Join(s.MetricsServer.Close(), s.shutdownTracing())
+		case <-ticker.C:
+		}
+
+		s.patrol(ctx)
+	}
+}
+
+// patrol does one round of work, traced from looking up the attractions to finishing the job
+func (s *Staff) patrol(ctx context.Context) {
+	ctx, span := tracing.Start(ctx, "staff.patrol", attribute.String("staff.role", s.Config.Role))
+	defer span.End()
+
+	if err := s.Work(ctx, s.Config.ParkURL); err != nil {
+		span.RecordError(err)
+		slog.WarnContext(ctx, "Failed to do work", "role", s.Config.Role, "error", err)
+	}
+}
+
+// serviceAccountToken reads the pod's ServiceAccount token, which identifies this member of staff.
+// The token is rotated by Kubernetes, so it is read for every request.
+func serviceAccountToken() (string, error) {
+	token, err := os.ReadFile(auth.ServiceAccountTokenPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token: %v", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// heartbeat tells the park this member of staff is on duty, identified by the pod's ServiceAccount token
+func (s *Staff) heartbeat(ctx context.Context) error {
+	token, err := serviceAccountToken()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(httptypes.StaffHeartbeat{
+		Role: s.Config.Role,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Config.ParkURL+"/staff", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := parkClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("heartbeat failed with status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func main() {
+	staff := New()
+
+	// Go off duty on SIGTERM, after finishing the job at hand
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if err := staff.Start(ctx); err != nil {
+		slog.Error("Staff failed to start", "error", err)
+		panic(err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"kubepark/pkg/directory"
+	"kubepark/pkg/httptypes"
+	"kubepark/pkg/tracing"
+)
+
+// cleanThreshold is the dirtiness at which janitors start cleaning an attraction
+const cleanThreshold = 0.3
+
+// errBusy means another member of staff is already doing the job
+var errBusy = errors.New("someone else is already on the job")
+
+// maintenanceClient waits for staff to finish their jobs, which take a while
+var maintenanceClient = tracing.NewClient(5 * time.Minute)
+
+// repairBroken has a mechanic repair the first broken attraction it finds
+func repairBroken(ctx context.Context, parkURL string) error {
+	attractions, err := directory.Attractions(ctx, parkURL)
+	if err != nil {
+		return fmt.Errorf("failed to discover attractions: %v", err)
+	}
+
+	for _, attraction := range attractions {
+		if !attraction.Broken {
+			continue
+		}
+
+		slog.InfoContext(ctx, "Repairing attraction", "attraction", attraction.Name, "instance", attraction.Instance)
+		if err := doJob(ctx, httptypes.RoleMechanic, attraction, "/repair"); !errors.Is(err, errBusy) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cleanDirtiest has a janitor clean the dirtiest attraction that needs it
+func cleanDirtiest(ctx context.Context, parkURL string) error {
+	attractions, err := directory.Attractions(ctx, parkURL)
+	if err != nil {
+		return fmt.Errorf("failed to discover attractions: %v", err)
+	}
+
+	sort.Slice(attractions, func(i, j int) bool {
+		return attractions[i].Dirtiness > attractions[j].Dirtiness
+	})
+
+	for _, attraction := range attractions {
+		if attraction.Dirtiness < cleanThreshold {
+			break
+		}
+
+		slog.InfoContext(ctx, "Cleaning attraction", "attraction", attraction.Name, "instance", attraction.Instance, "dirtiness", attraction.Dirtiness)
+		if err := doJob(ctx, httptypes.RoleJanitor, attraction, "/clean"); !errors.Is(err, errBusy) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// doJob asks an attraction to be worked on and waits until the job is done. The attraction only
+// lets staff work on it, so the request carries the pod's ServiceAccount token.
+func doJob(ctx context.Context, role string, attraction httptypes.Attraction, endpoint string) error {
+	token, err := serviceAccountToken()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, attraction.URL+endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := maintenanceClient.Do(req)
+	if err != nil {
+		metrics.Jobs.WithLabelValues(role, "failed").Inc()
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		metrics.Jobs.WithLabelValues(role, "done").Inc()
+		slog.InfoContext(ctx, "Finished job", "role", role, "attraction", attraction.Name, "instance", attraction.Instance)
+		return nil
+	case http.StatusConflict:
+		metrics.Jobs.WithLabelValues(role, "skipped").Inc()
+		return errBusy
+	default:
+		metrics.Jobs.WithLabelValues(role, "failed").Inc()
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("job failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+}